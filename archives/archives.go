@@ -0,0 +1,308 @@
+package archives
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"hash/fnv"
+	"icu/data"
+	"icu/dupes"
+	"icu/extract"
+	"icu/filetype"
+	"icu/similar"
+	"icu/symbols"
+	"icu/utils"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Separator joins the path of an archive and the path of one of its members, as in
+// "backup.zip!/docs/report.txt".
+const Separator = "!/"
+
+// maxMemberSize caps how much of a single member is read into memory for content extraction.
+// Larger members are still indexed, only without their text.
+const maxMemberSize = 64 << 20
+
+// virtualInodeFlag marks inodes made up for archive members. Real inode numbers stay far below
+// 2^62, and sqlite integers are signed, so bit 62 is the highest one that can be used.
+const virtualInodeFlag = 1 << 62
+
+var archiveSuffixes = []string{".zip", ".tar", ".tar.gz", ".tgz"}
+
+// HasArchiveName reports whether name carries one of the archive extensions.
+func HasArchiveName(name string) bool {
+	name = strings.ToLower(name)
+	for _, suffix := range archiveSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsArchive reports whether the file is a container whose members should be indexed. Both the
+// name and the sniffed type have to agree, so a DOCX or a mislabelled file is never expanded.
+func IsArchive(fileType string, name string) bool {
+	if !HasArchiveName(name) {
+		return false
+	}
+	switch fileType {
+	case "application/zip", "application/x-tar", "application/gzip":
+		return true
+	}
+
+	return false
+}
+
+// Split separates a virtual member path into the path of the archive and the member path inside
+// it. It reports false for ordinary paths.
+func Split(entryPath string) (string, string, bool) {
+	offset := 0
+	for {
+		index := strings.Index(entryPath[offset:], Separator)
+		if index < 0 {
+			return "", "", false
+		}
+		archivePath := entryPath[:offset+index]
+		if HasArchiveName(archivePath) {
+			return archivePath, entryPath[offset+index+len(Separator):], true
+		}
+		offset += index + len(Separator)
+	}
+}
+
+// VirtualInode derives a stable inode for an archive member from its full virtual path.
+func VirtualInode(entryPath string) uint64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(entryPath))
+
+	return hash.Sum64()&(virtualInodeFlag-1) | virtualInodeFlag
+}
+
+// MemberFunc receives every member of an archive as soon as it is read, so an archive is never
+// held in memory as a whole. failure is set when the content of the member could not be read, the
+// member is still indexed by its metadata.
+type MemberFunc func(member *data.EntryCollection, failure *data.NotAccessedPaths)
+
+// Expand hands the members of the archive behind entry to emit as virtual entries, with their
+// content extracted where an extractor is registered. Nested archives are indexed as plain members.
+// A member whose path an earlier member already took is skipped, and reported in the error once
+// all other members were emitted.
+func Expand(archive *data.EntryCollection, emit MemberFunc) error {
+	paths := memberPaths{seen: make(map[string]bool)}
+	var err error
+	if archive.FileType == "application/zip" {
+		err = expandZip(archive, &paths, emit)
+	} else {
+		err = expandTarFile(archive, &paths, emit)
+	}
+	if err != nil {
+		return err
+	}
+
+	return paths.duplicateError(archive)
+}
+
+// memberPaths remembers the paths emitted for an archive. The index holds a single entry per path,
+// while a tar can hold a path more than once, appended with tar -r or stored as both ./x and x.
+type memberPaths struct {
+	seen       map[string]bool
+	duplicates []string
+}
+
+// add reports whether member is the first one with its path.
+func (p *memberPaths) add(member *data.EntryCollection) bool {
+	if p.seen[member.FullPath] {
+		p.duplicates = append(p.duplicates, member.FullPath)
+		return false
+	}
+	p.seen[member.FullPath] = true
+
+	return true
+}
+
+func (p *memberPaths) duplicateError(archive *data.EntryCollection) error {
+	if len(p.duplicates) == 0 {
+		return nil
+	}
+
+	var names []string
+	for _, duplicate := range p.duplicates {
+		if len(names) == 5 {
+			names = append(names, "...")
+			break
+		}
+		names = append(names, strings.TrimPrefix(duplicate, archive.FullPath+Separator))
+	}
+
+	return fmt.Errorf("%w: %s holds %d members under paths already taken by earlier members, only the first of each is indexed: %s",
+		utils.ErrDecode, archive.FullPath, len(p.duplicates), strings.Join(names, ", "))
+}
+
+func expandZip(archive *data.EntryCollection, paths *memberPaths, emit MemberFunc) error {
+	reader, err := zip.OpenReader(archive.FullPath)
+	if err != nil {
+		return fmt.Errorf("could not open archive %s: %w: %w", archive.FullPath, utils.ErrDecode, err)
+	}
+	defer reader.Close()
+
+	for _, file := range reader.File {
+		isDir := file.FileInfo().IsDir()
+		member, ok := newMember(archive, file.Name, isDir, int64(file.UncompressedSize64), file.Modified)
+		if !ok || !paths.add(member) {
+			continue
+		}
+		if isDir {
+			emit(member, nil)
+			continue
+		}
+		if file.UncompressedSize64 > maxMemberSize {
+			emit(member, tooLarge(member))
+			continue
+		}
+		content, err := file.Open()
+		if err == nil {
+			err = readMember(member, content)
+			content.Close()
+		}
+		emit(member, memberFailure(member, err))
+	}
+
+	return nil
+}
+
+func expandTarFile(archive *data.EntryCollection, paths *memberPaths, emit MemberFunc) error {
+	file, err := os.Open(archive.FullPath)
+	if err != nil {
+		return fmt.Errorf("could not open archive %s: %w", archive.FullPath, err)
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if archive.FileType == "application/gzip" {
+		gzipReader, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("could not decompress archive %s: %w: %w", archive.FullPath, utils.ErrDecode, err)
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	}
+
+	return expandTar(archive, tar.NewReader(reader), paths, emit)
+}
+
+func expandTar(archive *data.EntryCollection, reader *tar.Reader, paths *memberPaths, emit MemberFunc) error {
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("could not read archive %s: %w: %w", archive.FullPath, utils.ErrDecode, err)
+		}
+		if header.Typeflag != tar.TypeReg && header.Typeflag != tar.TypeDir {
+			continue
+		}
+
+		isDir := header.Typeflag == tar.TypeDir
+		member, ok := newMember(archive, header.Name, isDir, header.Size, header.ModTime)
+		if !ok || !paths.add(member) {
+			continue
+		}
+		member.OwnerID = uint32(header.Uid)
+		member.GroupID = uint32(header.Gid)
+		if isDir {
+			emit(member, nil)
+			continue
+		}
+		if header.Size > maxMemberSize {
+			emit(member, tooLarge(member))
+			continue
+		}
+		err = readMember(member, reader)
+		emit(member, memberFailure(member, err))
+	}
+
+	return nil
+}
+
+func memberFailure(member *data.EntryCollection, err error) *data.NotAccessedPaths {
+	if err == nil {
+		return nil
+	}
+
+	return utils.NewFailure(member.FullPath, err)
+}
+
+func tooLarge(member *data.EntryCollection) *data.NotAccessedPaths {
+	err := fmt.Errorf("member %s is %s, members are read up to %s: %w",
+		member.FullPath, utils.FormatSize(member.Size), utils.FormatSize(maxMemberSize), utils.ErrTooLarge)
+
+	return utils.NewFailure(member.FullPath, err)
+}
+
+func newMember(archive *data.EntryCollection, name string, isDir bool, size int64, modified time.Time) (*data.EntryCollection, bool) {
+	memberPath := strings.TrimPrefix(path.Clean("/"+name), "/")
+	if memberPath == "" {
+		return nil, false
+	}
+
+	member := data.EntryCollection{}
+	member.FullPath = archive.FullPath + Separator + memberPath
+	member.ParentDirID = archive.FullPath
+	if parent := path.Dir(memberPath); parent != "." {
+		member.ParentDirID = archive.FullPath + Separator + parent
+	}
+	member.Name = path.Base(memberPath)
+	member.Device = archive.Device
+	member.Inode = VirtualInode(member.FullPath)
+	member.IsDir = isDir
+	member.Size = size
+	member.ModificationTime = modified
+	member.AccessTime = archive.AccessTime
+	member.MetaDataChangeTime = archive.MetaDataChangeTime
+	member.OwnerID = archive.OwnerID
+	member.GroupID = archive.GroupID
+	member.ArchivePath = archive.FullPath
+	member.Extension = filepath.Ext(member.Name)
+	member.EntryType = filetype.EntryFile
+	member.FileType = filetype.Detect(member.Name, nil)
+	if isDir {
+		member.EntryType = filetype.EntryDirectory
+		member.FileType = filetype.Directory
+		member.Size = 0
+	}
+
+	return &member, true
+}
+
+// readMember fills in the type, hash and content of a member. The metadata is kept even when the
+// content cannot be read or extracted.
+func readMember(member *data.EntryCollection, content io.Reader) error {
+	contents, err := io.ReadAll(io.LimitReader(content, maxMemberSize))
+	if err != nil {
+		return fmt.Errorf("could not read %s: %w", member.FullPath, err)
+	}
+
+	head := contents
+	if len(head) > 512 {
+		head = head[:512]
+	}
+	member.FileType = filetype.Detect(member.Name, head)
+	if int64(len(contents)) == member.Size {
+		member.ContentHash = dupes.HashBytes(contents)
+	}
+
+	_, err = extract.ContentFromBytes(member, contents)
+	symbols.Collect(member)
+	similar.Collect(member)
+
+	return err
+}
@@ -0,0 +1,151 @@
+package archives
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"errors"
+	"icu/data"
+	"icu/utils"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type member struct {
+	name    string
+	content string
+}
+
+func writeTar(t *testing.T, path string, members []member) {
+	t.Helper()
+
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	writer := tar.NewWriter(file)
+	for _, m := range members {
+		header := &tar.Header{Name: m.name, Mode: 0o644, Size: int64(len(m.content)), Typeflag: tar.TypeReg}
+		if strings.HasSuffix(m.name, "/") {
+			header.Typeflag, header.Size = tar.TypeDir, 0
+		}
+		if err := writer.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := writer.Write([]byte(m.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeZip(t *testing.T, path string, members []member) {
+	t.Helper()
+
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	writer := zip.NewWriter(file)
+	for _, m := range members {
+		content, err := writer.Create(m.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := content.Write([]byte(m.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// expand returns the member paths inside the archive in the order they were emitted, together with
+// the contents of the regular members.
+func expand(t *testing.T, archive *data.EntryCollection) ([]string, map[string]string, error) {
+	t.Helper()
+
+	var paths []string
+	contents := make(map[string]string)
+	err := Expand(archive, func(m *data.EntryCollection, failure *data.NotAccessedPaths) {
+		if failure != nil {
+			t.Errorf("unexpected failure for %s: %s", m.FullPath, failure.Err)
+		}
+		_, memberPath, _ := Split(m.FullPath)
+		paths = append(paths, memberPath)
+		if !m.IsDir {
+			contents[memberPath] = string(m.FullTextIndex)
+		}
+	})
+
+	return paths, contents, err
+}
+
+func TestExpandSkipsDuplicateMembers(t *testing.T) {
+	members := []member{
+		{"docs/", ""},
+		{"docs/m.txt", "first\n"},
+		{"./x.txt", "dot\n"},
+		{"x.txt", "plain\n"},
+		{"docs/m.txt", "appended\n"},
+		{"docs/../docs/m.txt", "unclean\n"},
+	}
+	dir := t.TempDir()
+
+	tests := []struct {
+		name     string
+		fileType string
+		write    func(*testing.T, string, []member)
+	}{
+		{"dup.tar", "application/x-tar", writeTar},
+		{"dup.zip", "application/zip", writeZip},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			archivePath := filepath.Join(dir, test.name)
+			test.write(t, archivePath, members)
+
+			archive := &data.EntryCollection{FileType: test.fileType}
+			archive.FullPath = archivePath
+			paths, contents, err := expand(t, archive)
+
+			if want := []string{"docs", "docs/m.txt", "x.txt"}; !reflect.DeepEqual(paths, want) {
+				t.Errorf("members got %v, want %v", paths, want)
+			}
+			if contents["docs/m.txt"] != "first\n" || contents["x.txt"] != "dot\n" {
+				t.Errorf("the first member of each path should win, got %q", contents)
+			}
+			if !errors.Is(err, utils.ErrDecode) {
+				t.Fatalf("got error %v, want %v", err, utils.ErrDecode)
+			}
+			if !strings.Contains(err.Error(), "holds 3 members") || !strings.Contains(err.Error(), "x.txt, docs/m.txt, docs/m.txt") {
+				t.Errorf("error does not name the duplicates: %v", err)
+			}
+		})
+	}
+}
+
+func TestExpandWithoutDuplicates(t *testing.T) {
+	archivePath := filepath.Join(t.TempDir(), "plain.tar")
+	writeTar(t, archivePath, []member{{"a.txt", "a\n"}, {"b/", ""}, {"b/a.txt", "b\n"}})
+
+	archive := &data.EntryCollection{FileType: "application/x-tar"}
+	archive.FullPath = archivePath
+	paths, _, err := expand(t, archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a.txt", "b", "b/a.txt"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("members got %v, want %v", paths, want)
+	}
+}
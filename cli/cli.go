@@ -4,32 +4,85 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"icu/dupes"
+	"icu/fuzzy"
+	"icu/grep"
 	"icu/initial"
 	"icu/maintain"
+	"icu/search"
 	"icu/setup"
+	"icu/similar"
+	"icu/symbols"
+	"icu/tagging"
+	"icu/tui"
 	"strings"
 )
 
+func runCommand(arguments []string) error {
+	switch arguments[0] {
+	case "test":
+		//test.Main()
+	case "setup":
+		return setup.Main()
+	case "fullscan":
+		return initial.Main(arguments[1:])
+	case "rollback":
+		return initial.RollbackMain(arguments[1:])
+	case "sync":
+		return maintain.Start()
+	case "search":
+		return search.Main(arguments[1:])
+	case "saved":
+		return search.SavedMain(arguments[1:])
+	case "fuzzy":
+		return fuzzy.Main(arguments[1:])
+	case "grep":
+		return grep.Main(arguments[1:])
+	case "tag":
+		return tagging.TagMain(arguments[1:])
+	case "untag":
+		return tagging.UntagMain(arguments[1:])
+	case "tags":
+		return tagging.TagsMain(arguments[1:])
+	case "taginherit":
+		return tagging.InheritMain(arguments[1:])
+	case "tagged":
+		return tagging.TaggedMain(arguments[1:])
+	case "dupes":
+		return dupes.Main(arguments[1:])
+	case "similar":
+		return similar.SimilarMain(arguments[1:])
+	case "near-dupes":
+		return similar.NearDupesMain(arguments[1:])
+	case "sym":
+		return symbols.Main(arguments[1:])
+	case "tui":
+		return tui.Main()
+	default:
+		fmt.Println(arguments)
+	}
+
+	return nil
+}
+
 func Main() {
+	if len(os.Args) > 1 {
+		err := runCommand(os.Args[1:])
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	for {
 		reader := bufio.NewReader(os.Stdin)
 		fmt.Print("> ")
 		input, _ := reader.ReadString('\n')
 		arguments := strings.Split(strings.TrimSpace(input), " ")
-		switch arguments[0] {
-		case "test":
-			//test.Main()
-		case "setup":
-			err := setup.Main()
-			if err != nil {
-				fmt.Println(err)
-			}
-		case "fullscan":
-			initial.StartInitialScan()
-		case "sync":
-			maintain.Start()
-		default:
-			fmt.Println(arguments)
+		err := runCommand(arguments)
+		if err != nil {
+			fmt.Println(err)
 		}
 	}
 
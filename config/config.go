@@ -0,0 +1,129 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+type Ranking struct {
+	TextWeight           float64 `json:"text_weight"`
+	ModifiedWeight       float64 `json:"modified_weight"`
+	AccessedWeight       float64 `json:"accessed_weight"`
+	DepthWeight          float64 `json:"depth_weight"`
+	ModifiedHalfLifeDays float64 `json:"modified_half_life_days"`
+	AccessedHalfLifeDays float64 `json:"accessed_half_life_days"`
+}
+
+// Writing controls how scans write to the database. Entries are committed in transactions of
+// BatchSize writes, and LogEntries prints every written path.
+type Writing struct {
+	BatchSize  int  `json:"batch_size"`
+	LogEntries bool `json:"log_entries"`
+}
+
+// Scanning controls what the scans walk into. With FollowSymlinks set, symlinks to directories
+// outside the indexed tree are descended into, every directory is still walked only once. Roots
+// holds options per scan root, keyed by its path.
+type Scanning struct {
+	FollowSymlinks bool            `json:"follow_symlinks"`
+	Roots          map[string]Root `json:"roots"`
+}
+
+// Root holds the options of one scan root. With OneFilesystem set, the scans stop at mount points
+// below the root, like find -xdev.
+type Root struct {
+	OneFilesystem bool `json:"one_filesystem"`
+}
+
+// Root returns the options of the scan root at path, the zero Root when none are configured.
+func (s Scanning) Root(path string) Root {
+	for rootPath, root := range s.Roots {
+		if filepath.Clean(rootPath) == filepath.Clean(path) {
+			return root
+		}
+	}
+
+	return Root{}
+}
+
+type Config struct {
+	Ranking  Ranking  `json:"ranking"`
+	Writing  Writing  `json:"writing"`
+	Scanning Scanning `json:"scanning"`
+}
+
+func Default() Config {
+	return Config{
+		Ranking: Ranking{
+			TextWeight:           1.0,
+			ModifiedWeight:       0.6,
+			AccessedWeight:       0.3,
+			DepthWeight:          0.2,
+			ModifiedHalfLifeDays: 30,
+			AccessedHalfLifeDays: 14,
+		},
+		Writing: Writing{
+			BatchSize:  1000,
+			LogEntries: false,
+		},
+		Scanning: Scanning{
+			FollowSymlinks: false,
+			Roots:          map[string]Root{},
+		},
+	}
+}
+
+func GetConfigPath() (string, error) {
+	homePath, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("could not resolve home directory: %w", err)
+	}
+
+	return filepath.Join(homePath, ".icu", "config.json"), nil
+}
+
+// Load reads ~/.icu/config.json on top of the defaults, so the file only needs the values that
+// differ. A missing file is not an error.
+func Load() (Config, error) {
+	loaded := Default()
+
+	configPath, err := GetConfigPath()
+	if err != nil {
+		return loaded, err
+	}
+	contents, err := os.ReadFile(configPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return loaded, nil
+	} else if err != nil {
+		return loaded, fmt.Errorf("could not read config %s: %w", configPath, err)
+	}
+
+	err = json.Unmarshal(contents, &loaded)
+	if err != nil {
+		return Default(), fmt.Errorf("could not parse config %s: %w", configPath, err)
+	}
+
+	return loaded, nil
+}
+
+func WriteDefault(servicePath string) error {
+	configPath := filepath.Join(servicePath, "config.json")
+	if _, err := os.Stat(configPath); err == nil {
+		return nil
+	}
+
+	contents, err := json.MarshalIndent(Default(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not serialize default config: %w", err)
+	}
+	err = os.WriteFile(configPath, append(contents, '\n'), 0o644)
+	if err != nil {
+		return fmt.Errorf("could not write default config %s: %w", configPath, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,539 @@
+package data
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+const (
+	insertEntryQuery = `insert into {entries}(
+                    device,
+                    inode,
+                    path,
+					parent_directory,
+					name,
+					is_dir,
+					size,
+					modification_time,
+					access_time,
+					metadata_change_time,
+					owner_id,
+					group_id,
+					extension,
+					filetype,
+					content_snippet,
+					full_text,
+                    line_count_total,
+                    line_count_w_content,
+                    title,
+                    metadata,
+                    archive_path,
+                    content_hash,
+                    minhash,
+                    entry_type,
+                    link_target,
+                    nlink)
+					values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	updateEntryWithContentQuery = `update {entries}
+    		  set
+                  device = ?,
+                  inode = ?,
+                  path = ?,
+				  parent_directory = ?,
+				  name = ?,
+				  is_dir = ?,
+				  size = ?,
+				  modification_time = ?,
+				  access_time = ?,
+				  metadata_change_time = ?,
+				  owner_id = ?,
+				  group_id = ?,
+				  extension = ?,
+				  filetype = ?,
+				  content_snippet = ?,
+				  full_text = ?,
+                  line_count_total = ?,
+                  line_count_w_content = ?,
+                  title = ?,
+                  metadata = ?,
+                  content_hash = ?,
+                  minhash = ?,
+                  entry_type = ?,
+                  link_target = ?,
+                  nlink = ?
+			  where entry_id = ?`
+
+	updateEntryWithoutContentQuery = `update {entries}
+    		  set
+                  device = ?,
+                  inode = ?,
+                  path = ?,
+				  parent_directory = ?,
+				  name = ?,
+				  is_dir = ?,
+				  size = ?,
+				  modification_time = ?,
+				  access_time = ?,
+				  metadata_change_time = ?,
+				  owner_id = ?,
+				  group_id = ?,
+				  extension = ?,
+				  entry_type = ?,
+				  link_target = ?,
+				  nlink = ?
+			  where entry_id = ?`
+
+	insertFullTextQuery       = `insert into {fts}(rowid, name, path, full_text) values (?, ?, ?, ?)`
+	updateFullTextHeaderQuery = `update {fts} set name = ?, path = ? where rowid = ?`
+	deleteFullTextQuery       = `delete from {fts} where rowid = ?`
+	insertSymbolQuery         = `insert into {symbols}(entry_id, name, kind, package, receiver, line, doc) values (?, ?, ?, ?, ?, ?, ?)`
+	deleteSymbolsQuery        = `delete from {symbols} where entry_id = ?`
+	insertIgnoredQuery        = `insert into {ignored}(path, error, error_class, recorded_at) values(?, ?, ?, ?)`
+	updateContentHashQuery    = `update {entries} set content_hash = ? where device = ? and inode = ?`
+	deleteEntryQuery          = `delete from {entries} where entry_id = ?`
+	updateLinkCountQuery      = `update {entries} set nlink = ? where device = ? and inode = ? and archive_path is null`
+	removeLinkQuery           = `update {entries} set nlink = nlink - 1
+										where device = ? and inode = ? and entry_id != ?
+											and is_dir = 0 and archive_path is null and nlink > 1`
+	deleteIgnoredQuery    = `delete from {ignored} where path = ?`
+	insertCheckpointQuery = `insert or ignore into scan_checkpoints(scan_id, directory) values (?, ?)`
+	savepointEntryQuery   = `savepoint entry`
+	releaseEntryQuery     = `release entry`
+	rollbackEntryQuery    = `rollback to entry`
+)
+
+// ErrEntryRejected marks an entry the database refused on its own account, such as one whose path
+// is already taken. Nothing of the entry is written and the batch stays usable.
+var ErrEntryRejected = errors.New("entry rejected")
+
+// Tables names the tables a BatchWriter writes to. A full scan fills the next generation while the
+// live tables keep answering searches.
+type Tables struct {
+	Entries  string
+	FullText string
+	Symbols  string
+	Ignored  string
+}
+
+func GenerationTables(suffix string) Tables {
+	return Tables{
+		Entries:  "entries" + suffix,
+		FullText: "entries_fts" + suffix,
+		Symbols:  "symbols" + suffix,
+		Ignored:  "ignored_entries" + suffix,
+	}
+}
+
+var LiveTables = GenerationTables("")
+
+// BatchWriter groups entry writes into transactions of batchSize entries. Every statement is
+// prepared once per transaction and reused for all entries in it. Commit has to be called after
+// the last write, a BatchWriter is not safe for concurrent use.
+type BatchWriter struct {
+	con        *sql.DB
+	names      *strings.Replacer
+	batchSize  int
+	logEntries bool
+	tx         *sql.Tx
+	statements map[string]*sql.Stmt
+	pending    int
+}
+
+func NewBatchWriter(con *sql.DB, tables Tables, batchSize int, logEntries bool) *BatchWriter {
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	names := strings.NewReplacer(
+		"{entries}", tables.Entries,
+		"{fts}", tables.FullText,
+		"{symbols}", tables.Symbols,
+		"{ignored}", tables.Ignored)
+
+	return &BatchWriter{con: con, names: names, batchSize: batchSize, logEntries: logEntries}
+}
+
+func (w *BatchWriter) exec(query string, args ...any) error {
+	_, err := w.execResult(query, args...)
+	return err
+}
+
+func (w *BatchWriter) execResult(query string, args ...any) (sql.Result, error) {
+	if w.tx == nil {
+		tx, err := w.con.Begin()
+		if err != nil {
+			return nil, fmt.Errorf("could not begin write transaction: %w", err)
+		}
+		w.tx = tx
+		w.statements = make(map[string]*sql.Stmt)
+	}
+
+	statement, ok := w.statements[query]
+	if !ok {
+		var err error
+		statement, err = w.tx.Prepare(w.names.Replace(query))
+		if err != nil {
+			return nil, fmt.Errorf("could not prepare statement: %s\n%w", w.names.Replace(query), err)
+		}
+		w.statements[query] = statement
+	}
+
+	return statement.Exec(args...)
+}
+
+// written counts a finished entry and commits the transaction once the batch is full.
+func (w *BatchWriter) written(action string, path string) error {
+	if w.logEntries {
+		fmt.Println(action, path)
+	}
+	w.pending++
+	if w.pending >= w.batchSize {
+		return w.Commit()
+	}
+
+	return nil
+}
+
+// Pending reports whether writes are waiting for the next commit.
+func (w *BatchWriter) Pending() bool {
+	return w.tx != nil
+}
+
+// Commit ends the current transaction. The prepared statements are closed with it.
+func (w *BatchWriter) Commit() error {
+	if w.tx == nil {
+		return nil
+	}
+
+	err := w.tx.Commit()
+	w.tx = nil
+	w.statements = nil
+	w.pending = 0
+	if err != nil {
+		return fmt.Errorf("could not commit write transaction: %w", err)
+	}
+
+	return nil
+}
+
+// Rollback discards the writes since the last commit. It does nothing after a commit, so it can be
+// deferred right after creating the writer.
+func (w *BatchWriter) Rollback() error {
+	if w.tx == nil {
+		return nil
+	}
+
+	err := w.tx.Rollback()
+	w.tx = nil
+	w.statements = nil
+	w.pending = 0
+	if err != nil {
+		return fmt.Errorf("could not roll back write transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (w *BatchWriter) WriteEntry(entry *EntryCollection) error {
+	metadata, err := encodeMetadata(entry.Metadata)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrEntryRejected, err)
+	}
+	err = w.exec(savepointEntryQuery)
+	if err != nil {
+		return err
+	}
+	err = w.insertEntry(entry, metadata)
+	if err != nil {
+		if !rejected(err) {
+			return err
+		}
+		// undo whatever part of the entry made it in, the rest of the batch is kept
+		rollbackErr := w.exec(rollbackEntryQuery)
+		if rollbackErr == nil {
+			rollbackErr = w.exec(releaseEntryQuery)
+		}
+		if rollbackErr != nil {
+			return rollbackErr
+		}
+		return fmt.Errorf("%w: %w", ErrEntryRejected, err)
+	}
+	err = w.exec(releaseEntryQuery)
+	if err != nil {
+		return err
+	}
+
+	return w.written("Wrote entry:", entry.FullPath)
+}
+
+func (w *BatchWriter) insertEntry(entry *EntryCollection, metadata any) error {
+	result, err := w.execResult(
+		insertEntryQuery,
+		entry.Device,
+		entry.Inode,
+		entry.FullPath,
+		entry.ParentDirID,
+		entry.Name,
+		entry.IsDir,
+		entry.Size,
+		entry.ModificationTime,
+		entry.AccessTime,
+		entry.MetaDataChangeTime,
+		entry.OwnerID,
+		entry.GroupID,
+		entry.Extension,
+		entry.FileType,
+		entry.ContentSnippet,
+		entry.FullTextIndex,
+		entry.LineCountTotal,
+		entry.LineCountWithContent,
+		entry.Title,
+		metadata,
+		nullable(entry.ArchivePath),
+		nullable(entry.ContentHash),
+		entry.MinHash,
+		entry.EntryType,
+		nullable(entry.LinkTarget),
+		nullableLinks(entry.Nlink))
+	if err != nil {
+		return fmt.Errorf("could not write entry %s to database: \n%w", entry.FullPath, err)
+	}
+	entry.EntryID, err = result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("could not read id of entry %s: %w", entry.FullPath, err)
+	}
+	err = w.writeFullTextEntry(entry)
+	if err != nil {
+		return err
+	}
+
+	return w.writeSymbols(entry)
+}
+
+// rejected reports whether a failed statement concerns the values of a single entry, rather than the
+// database or the transaction.
+func rejected(err error) bool {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	switch sqliteErr.Code {
+	case sqlite3.ErrConstraint, sqlite3.ErrMismatch, sqlite3.ErrTooBig, sqlite3.ErrRange:
+		return true
+	}
+
+	return false
+}
+
+func (w *BatchWriter) UpdateEntryWithContent(entry *EntryCollection) error {
+	metadata, err := encodeMetadata(entry.Metadata)
+	if err != nil {
+		return err
+	}
+	err = w.exec(
+		updateEntryWithContentQuery,
+		entry.Device,
+		entry.Inode,
+		entry.FullPath,
+		entry.ParentDirID,
+		entry.Name,
+		entry.IsDir,
+		entry.Size,
+		entry.ModificationTime,
+		entry.AccessTime,
+		entry.MetaDataChangeTime,
+		entry.OwnerID,
+		entry.GroupID,
+		entry.Extension,
+		entry.FileType,
+		entry.ContentSnippet,
+		entry.FullTextIndex,
+		entry.LineCountTotal,
+		entry.LineCountWithContent,
+		entry.Title,
+		metadata,
+		nullable(entry.ContentHash),
+		entry.MinHash,
+		entry.EntryType,
+		nullable(entry.LinkTarget),
+		nullableLinks(entry.Nlink),
+		entry.EntryID)
+	if err != nil {
+		return fmt.Errorf("could not update entry %s in database: \n%w", entry.FullPath, err)
+	}
+	err = w.exec(deleteFullTextQuery, entry.EntryID)
+	if err != nil {
+		return fmt.Errorf("could not delete full text entry %d from database: \n%w", entry.EntryID, err)
+	}
+	err = w.writeFullTextEntry(entry)
+	if err != nil {
+		return err
+	}
+	err = w.exec(deleteSymbolsQuery, entry.EntryID)
+	if err != nil {
+		return fmt.Errorf("could not delete symbols from database: %w", err)
+	}
+	err = w.writeSymbols(entry)
+	if err != nil {
+		return err
+	}
+
+	return w.written("Updated entry with content:", entry.FullPath)
+}
+
+func (w *BatchWriter) UpdateEntryWithoutContent(entry *EntryCollection) error {
+	err := w.exec(
+		updateEntryWithoutContentQuery,
+		entry.Device,
+		entry.Inode,
+		entry.FullPath,
+		entry.ParentDirID,
+		entry.Name,
+		entry.IsDir,
+		entry.Size,
+		entry.ModificationTime,
+		entry.AccessTime,
+		entry.MetaDataChangeTime,
+		entry.OwnerID,
+		entry.GroupID,
+		entry.Extension,
+		entry.EntryType,
+		nullable(entry.LinkTarget),
+		nullableLinks(entry.Nlink),
+		entry.EntryID)
+	if err != nil {
+		return fmt.Errorf("could not update entry %s in database: \n%w", entry.FullPath, err)
+	}
+	err = w.exec(updateFullTextHeaderQuery, entry.Name, entry.FullPath, entry.EntryID)
+	if err != nil {
+		return fmt.Errorf("could not update full text entry %s in database: \n%w", entry.FullPath, err)
+	}
+
+	return w.written("Updated entry without content:", entry.FullPath)
+}
+
+func (w *BatchWriter) WriteNotRegisteredEntry(entry *NotAccessedPaths) error {
+	err := w.exec(insertIgnoredQuery, entry.Path, entry.Err, entry.Class, entry.RecordedAt)
+	if err != nil {
+		return fmt.Errorf("could not write ignored entry %s to database: %w", entry.Path, err)
+	}
+
+	return w.written("Wrote ignored entry:", entry.Path)
+}
+
+// DeleteEntry removes an entry with its full text and symbols.
+func (w *BatchWriter) DeleteEntry(entryID int64) error {
+	err := w.exec(deleteFullTextQuery, entryID)
+	if err != nil {
+		return fmt.Errorf("could not delete full text entry %d from database: \n%w", entryID, err)
+	}
+	err = w.exec(deleteSymbolsQuery, entryID)
+	if err != nil {
+		return fmt.Errorf("could not delete symbols from database: %w", err)
+	}
+	err = w.exec(deleteEntryQuery, entryID)
+	if err != nil {
+		return fmt.Errorf("could not delete entry %d from database: %w", entryID, err)
+	}
+
+	return w.written("Deleted entry:", fmt.Sprint(entryID))
+}
+
+// UpdateLinkCount stores the link count of a file on all of its indexed paths, since adding a
+// hard link changes the count of the paths that are already indexed as well.
+func (w *BatchWriter) UpdateLinkCount(file FileID, nlink uint64) error {
+	err := w.exec(updateLinkCountQuery, nullableLinks(nlink), file.Device, file.Inode)
+	if err != nil {
+		return fmt.Errorf("could not update link count of inode %d on device %d: %w", file.Inode, file.Device, err)
+	}
+
+	return w.written("Updated link count of inode:", fmt.Sprint(file.Inode))
+}
+
+// RemoveLink takes the link of a deleted path off the count of the other indexed paths of its file.
+func (w *BatchWriter) RemoveLink(deleted InodeHeader) error {
+	if deleted.IsDir {
+		return nil
+	}
+	err := w.exec(removeLinkQuery, deleted.Device, deleted.Inode, deleted.EntryID)
+	if err != nil {
+		return fmt.Errorf("could not update link count of %s: %w", deleted.Path, err)
+	}
+
+	return nil
+}
+
+func (w *BatchWriter) DeleteNotRegisteredEntry(path string) error {
+	err := w.exec(deleteIgnoredQuery, path)
+	if err != nil {
+		return fmt.Errorf("could not delete ignored entry %s from database: %w", path, err)
+	}
+
+	return w.written("Deleted ignored entry:", path)
+}
+
+// WriteCheckpoint records a directory whose whole subtree is written, so a resumed scan can skip it.
+func (w *BatchWriter) WriteCheckpoint(scanID int64, directory string) error {
+	err := w.exec(insertCheckpointQuery, scanID, directory)
+	if err != nil {
+		return fmt.Errorf("could not write checkpoint for %s: %w", directory, err)
+	}
+
+	return w.written("Completed directory:", directory)
+}
+
+func (w *BatchWriter) UpdateContentHash(file FileID, contentHash string) error {
+	err := w.exec(updateContentHashQuery, contentHash, file.Device, file.Inode)
+	if err != nil {
+		return fmt.Errorf("could not store content hash of entry %d: %w", file.Inode, err)
+	}
+
+	return w.written("Stored content hash of entry:", fmt.Sprint(file.Inode))
+}
+
+// DeleteArchiveMembers removes the virtual entries of an archive so it can be expanded again.
+func (w *BatchWriter) DeleteArchiveMembers(archivePath string) error {
+	ftsQuery := `delete from {fts} where rowid in (select entry_id from {entries} where archive_path = ?)`
+	err := w.exec(ftsQuery, archivePath)
+	if err != nil {
+		return fmt.Errorf("could not delete full text entries of archive %s: %w", archivePath, err)
+	}
+
+	symbolsQuery := `delete from {symbols} where entry_id in (select entry_id from {entries} where archive_path = ?)`
+	err = w.exec(symbolsQuery, archivePath)
+	if err != nil {
+		return fmt.Errorf("could not delete symbols of archive %s: %w", archivePath, err)
+	}
+
+	query := `delete from {entries} where archive_path = ?`
+	err = w.exec(query, archivePath)
+	if err != nil {
+		return fmt.Errorf("could not delete members of archive %s: %w", archivePath, err)
+	}
+
+	return w.written("Deleted members of archive:", archivePath)
+}
+
+func (w *BatchWriter) writeFullTextEntry(entry *EntryCollection) error {
+	err := w.exec(insertFullTextQuery, entry.EntryID, entry.Name, entry.FullPath, string(entry.FullTextIndex))
+	if err != nil {
+		return fmt.Errorf("could not write full text entry %s to database: \n%w", entry.FullPath, err)
+	}
+
+	return nil
+}
+
+func (w *BatchWriter) writeSymbols(entry *EntryCollection) error {
+	for _, symbol := range entry.Symbols {
+		err := w.exec(insertSymbolQuery, entry.EntryID, symbol.Name, symbol.Kind, symbol.Package, symbol.Receiver, symbol.Line, symbol.Doc)
+		if err != nil {
+			return fmt.Errorf("could not write symbol %s of %s: %w", symbol.Name, entry.FullPath, err)
+		}
+	}
+
+	return nil
+}
@@ -3,37 +3,529 @@ package data
 import (
 	"database/sql"
 	"fmt"
+	"strings"
+	"time"
 )
 
-func GetInodeMappedEntries(con *sql.DB) (inodeMappedEntries map[uint64]InodeHeader, err error) {
-	inodeMappedEntries = make(map[uint64]InodeHeader)
+// GetPathMappedEntries maps every indexed path to the state sync compares the file system with.
+// Hard links share their device and inode, so paths are the key.
+func GetPathMappedEntries(con *sql.DB) (pathMappedEntries map[string]InodeHeader, err error) {
+	pathMappedEntries = make(map[string]InodeHeader)
+	query := `select entry_id, coalesce(device, 0), inode, is_dir, path, size, modification_time, metadata_change_time
+				from entries;`
+	response, err := con.Query(query)
 	if err != nil {
-		return inodeMappedEntries, err
+		return pathMappedEntries, fmt.Errorf("failed to load indexed entries: %w", err)
 	}
-	var query string
-	var response *sql.Rows
-	query = `select inode, path, modification_time, metadata_change_time 
-				from entries
-				order by inode;`
-	response, err = con.Query(query)
+	defer response.Close()
 
 	for response.Next() {
-		var inode uint64
 		var details InodeHeader
 		err = response.Scan(
-			&inode,
+			&details.EntryID,
+			&details.Device,
+			&details.Inode,
+			&details.IsDir,
 			&details.Path,
+			&details.Size,
 			&details.ModificationTime,
 			&details.MetaDataChangeTime,
 		)
 		if err != nil {
-			return inodeMappedEntries, fmt.Errorf("failed to serialize entry details to map: %v", err)
+			return pathMappedEntries, fmt.Errorf("failed to serialize entry details to map: %v", err)
+		}
+		pathMappedEntries[details.Path] = details
+	}
+	if err = response.Err(); err != nil {
+		return pathMappedEntries, fmt.Errorf("failed to iterate through db response: %v", err)
+	}
+
+	return pathMappedEntries, nil
+}
+
+// QueryEntries returns entries matching the where condition sorted by orderBy, which may refer to
+// the bm25 rank of rankMatch as "rank". Both where and orderBy are sql fragments built by the caller.
+func QueryEntries(con *sql.DB, where string, args []any, rankMatch string, orderBy string, limit int) ([]SearchResult, error) {
+	query := `select e.path, e.parent_directory, e.name, e.size, e.modification_time, e.access_time, e.entry_id, 0 as rank
+				from entries e
+				where ` + where + `
+				order by ` + orderBy + `
+				limit ?;`
+	queryArgs := append([]any{}, args...)
+	if rankMatch != "" {
+		query = `select e.path, e.parent_directory, e.name, e.size, e.modification_time, e.access_time, e.entry_id, coalesce(r.rank, 0) as rank
+				from entries e
+				left join (select rowid, bm25(entries_fts) as rank from entries_fts where entries_fts match ?) r
+					on r.rowid = e.entry_id
+				where ` + where + `
+				order by ` + orderBy + `
+				limit ?;`
+		queryArgs = append([]any{rankMatch}, queryArgs...)
+	}
+	queryArgs = append(queryArgs, limit)
+
+	response, err := con.Query(query, queryArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run search query: %w", err)
+	}
+	defer response.Close()
+
+	var results []SearchResult
+	for response.Next() {
+		var result SearchResult
+		err = response.Scan(
+			&result.Path,
+			&result.ParentDirectory,
+			&result.Name,
+			&result.Size,
+			&result.ModificationTime,
+			&result.AccessTime,
+			&result.EntryID,
+			&result.Rank,
+		)
+		if err != nil {
+			return results, fmt.Errorf("failed to serialize search result: %v", err)
+		}
+		results = append(results, result)
+	}
+	if err = response.Err(); err != nil {
+		return results, fmt.Errorf("failed to iterate through db response: %v", err)
+	}
+
+	return results, nil
+}
+
+func GetSearchCandidates(con *sql.DB) ([]SearchResult, error) {
+	query := `select path, parent_directory, name, size, modification_time, access_time, entry_id
+				from entries;`
+	response, err := con.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load search candidates: %w", err)
+	}
+	defer response.Close()
+
+	var candidates []SearchResult
+	for response.Next() {
+		var candidate SearchResult
+		err = response.Scan(
+			&candidate.Path,
+			&candidate.ParentDirectory,
+			&candidate.Name,
+			&candidate.Size,
+			&candidate.ModificationTime,
+			&candidate.AccessTime,
+			&candidate.EntryID,
+		)
+		if err != nil {
+			return candidates, fmt.Errorf("failed to serialize search candidate: %v", err)
+		}
+		candidates = append(candidates, candidate)
+	}
+	if err = response.Err(); err != nil {
+		return candidates, fmt.Errorf("failed to iterate through db response: %v", err)
+	}
+
+	return candidates, nil
+}
+
+func GetFullText(con *sql.DB, entryID int64) (string, error) {
+	query := `select coalesce(full_text, '') from entries where entry_id = ?;`
+
+	var fullText string
+	err := con.QueryRow(query, entryID).Scan(&fullText)
+	if err == sql.ErrNoRows {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("failed to read full text of entry %d: %w", entryID, err)
+	}
+
+	return fullText, nil
+}
+
+func ForEachContent(con *sql.DB, filter ContentFilter, handle func(path string, fullText []byte) error) error {
+	conditions := []string{"full_text is not null", "length(full_text) > 0"}
+	var args []any
+
+	if filter.PathPrefix != "" {
+		directory := strings.TrimSuffix(filter.PathPrefix, "/") + "/"
+		conditions = append(conditions, "(path = ? or substr(path, 1, ?) = ?)")
+		args = append(args, filter.PathPrefix, len(directory), directory)
+	}
+	if len(filter.Extensions) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(filter.Extensions)), ", ")
+		conditions = append(conditions, "lower(extension) in ("+placeholders+")")
+		for _, extension := range filter.Extensions {
+			args = append(args, strings.ToLower(extension))
+		}
+	}
+	if filter.Contains != "" {
+		conditions = append(conditions, "instr(full_text, ?) > 0")
+		args = append(args, filter.Contains)
+	}
+
+	query := `select path, full_text
+				from entries
+				where ` + strings.Join(conditions, " and ") + `
+				order by path;`
+	response, err := con.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query indexed content: %w", err)
+	}
+	defer response.Close()
+
+	for response.Next() {
+		var path string
+		var fullText []byte
+		err = response.Scan(&path, &fullText)
+		if err != nil {
+			return fmt.Errorf("failed to serialize indexed content: %v", err)
+		}
+		err = handle(path, fullText)
+		if err != nil {
+			return err
+		}
+	}
+	if err = response.Err(); err != nil {
+		return fmt.Errorf("failed to iterate through db response: %v", err)
+	}
+
+	return nil
+}
+
+func GetSavedSearches(con *sql.DB) ([]SavedSearch, error) {
+	query := `select name, query, created_at, updated_at
+				from saved_searches
+				order by name;`
+	response, err := con.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load saved searches: %w", err)
+	}
+	defer response.Close()
+
+	var savedSearches []SavedSearch
+	for response.Next() {
+		var savedSearch SavedSearch
+		err = response.Scan(
+			&savedSearch.Name,
+			&savedSearch.Query,
+			&savedSearch.CreatedAt,
+			&savedSearch.UpdatedAt,
+		)
+		if err != nil {
+			return savedSearches, fmt.Errorf("failed to serialize saved search: %v", err)
+		}
+		savedSearches = append(savedSearches, savedSearch)
+	}
+	if err = response.Err(); err != nil {
+		return savedSearches, fmt.Errorf("failed to iterate through db response: %v", err)
+	}
+
+	return savedSearches, nil
+}
+
+func GetSavedSearch(con *sql.DB, name string) (SavedSearch, error) {
+	query := `select name, query, created_at, updated_at
+				from saved_searches
+				where name = ?;`
+
+	var savedSearch SavedSearch
+	err := con.QueryRow(query, name).Scan(
+		&savedSearch.Name,
+		&savedSearch.Query,
+		&savedSearch.CreatedAt,
+		&savedSearch.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return savedSearch, fmt.Errorf("no saved search named %q", name)
+	} else if err != nil {
+		return savedSearch, fmt.Errorf("failed to load saved search %q: %w", name, err)
+	}
+
+	return savedSearch, nil
+}
+
+func GetFileByPath(con *sql.DB, path string) (FileID, error) {
+	query := `select coalesce(device, 0), inode from entries where path = ?;`
+
+	var file FileID
+	err := con.QueryRow(query, path).Scan(&file.Device, &file.Inode)
+	if err == sql.ErrNoRows {
+		return file, fmt.Errorf("%s is not indexed, run a scan first", path)
+	} else if err != nil {
+		return file, fmt.Errorf("failed to look up %s: %w", path, err)
+	}
+
+	return file, nil
+}
+
+func CountArchiveMembers(con *sql.DB, archivePath string) (int, error) {
+	query := `select count(*) from entries where archive_path = ?;`
+
+	var count int
+	err := con.QueryRow(query, archivePath).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count members of %s: %w", archivePath, err)
+	}
+
+	return count, nil
+}
+
+func GetEntryTags(con *sql.DB, file FileID, path string) ([]EntryTag, error) {
+	query := `select t.name, ''
+				from tagged_entries te
+				join tags t on t.tag_id = te.tag_id
+				where te.device = ? and te.inode = ?
+				union
+				select t.name, d.path
+				from tagged_entries te
+				join tags t on t.tag_id = te.tag_id
+				join entries d on d.device = te.device and d.inode = te.inode
+				where d.is_dir = 1 and t.inherit = 1
+					and substr(?, 1, length(d.path) + 1) = d.path || '/'
+				order by 1, 2;`
+	response, err := con.Query(query, file.Device, file.Inode, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tags: %w", err)
+	}
+	defer response.Close()
+
+	var tags []EntryTag
+	for response.Next() {
+		var tag EntryTag
+		err = response.Scan(&tag.Name, &tag.InheritedFrom)
+		if err != nil {
+			return tags, fmt.Errorf("failed to serialize tag: %v", err)
+		}
+		tags = append(tags, tag)
+	}
+	if err = response.Err(); err != nil {
+		return tags, fmt.Errorf("failed to iterate through db response: %v", err)
+	}
+
+	return tags, nil
+}
+
+func QuerySymbols(con *sql.DB, where string, args []any, orderBy string, limit int) ([]Symbol, error) {
+	query := `select s.entry_id, e.path, s.name, s.kind, coalesce(s.package, ''), coalesce(s.receiver, ''), s.line, coalesce(s.doc, '')
+				from symbols s
+				join entries e on e.entry_id = s.entry_id
+				where ` + where + `
+				order by ` + orderBy + `
+				limit ?;`
+	queryArgs := append(append([]any{}, args...), limit)
+
+	response, err := con.Query(query, queryArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run symbol query: %w", err)
+	}
+	defer response.Close()
+
+	var symbols []Symbol
+	for response.Next() {
+		var symbol Symbol
+		err = response.Scan(
+			&symbol.EntryID,
+			&symbol.Path,
+			&symbol.Name,
+			&symbol.Kind,
+			&symbol.Package,
+			&symbol.Receiver,
+			&symbol.Line,
+			&symbol.Doc,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read symbol: %w", err)
+		}
+		symbols = append(symbols, symbol)
+	}
+	if err = response.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate through db response: %w", err)
+	}
+
+	return symbols, nil
+}
+
+func CountEntriesOfSize(con *sql.DB, size int64, exclude FileID) (int, error) {
+	query := `select count(*) from entries
+				where is_dir = 0 and coalesce(entry_type, 'file') = 'file' and size = ?
+					and not (coalesce(device, 0) = ? and inode = ?);`
+
+	var count int
+	err := con.QueryRow(query, size, exclude.Device, exclude.Inode).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count entries of size %d: %w", size, err)
+	}
+
+	return count, nil
+}
+
+// GetDuplicateCandidates returns the files below pathPrefix that share their size with at least
+// one other file in scope, largest first. Only these can have identical content.
+func GetDuplicateCandidates(con *sql.DB, minSize int64, pathPrefix string) ([]DuplicateCandidate, error) {
+	// links and special files are never opened, so they cannot be hashed
+	scope := "is_dir = 0 and coalesce(entry_type, 'file') = 'file' and size >= ?"
+	scopeArgs := []any{minSize}
+	if pathPrefix != "" {
+		directory := strings.TrimSuffix(pathPrefix, "/") + "/"
+		scope += " and substr(path, 1, ?) = ?"
+		scopeArgs = append(scopeArgs, len(directory), directory)
+	}
+
+	query := `select coalesce(device, 0), inode, path, size, coalesce(content_hash, ''), coalesce(archive_path, '')
+				from entries
+				where ` + scope + `
+					and size in (select size from entries where ` + scope + ` group by size having count(*) > 1)
+				order by size desc, path;`
+	args := append(append([]any{}, scopeArgs...), scopeArgs...)
+
+	response, err := con.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query duplicate candidates: %w", err)
+	}
+	defer response.Close()
+
+	var candidates []DuplicateCandidate
+	for response.Next() {
+		var candidate DuplicateCandidate
+		err = response.Scan(&candidate.File.Device, &candidate.File.Inode, &candidate.Path, &candidate.Size, &candidate.ContentHash, &candidate.ArchivePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read duplicate candidate: %w", err)
+		}
+		candidates = append(candidates, candidate)
+	}
+	if err = response.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate through db response: %w", err)
+	}
+
+	return candidates, nil
+}
+
+// GetFingerprints returns the MinHash signatures of all documents below pathPrefix, or of every
+// document when pathPrefix is empty.
+func GetFingerprints(con *sql.DB, pathPrefix string) ([]Fingerprint, error) {
+	query := `select coalesce(device, 0), inode, path, minhash from entries where minhash is not null`
+	var args []any
+	if pathPrefix != "" {
+		directory := strings.TrimSuffix(pathPrefix, "/") + "/"
+		query += " and substr(path, 1, ?) = ?"
+		args = append(args, len(directory), directory)
+	}
+	query += " order by path;"
+
+	response, err := con.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query fingerprints: %w", err)
+	}
+	defer response.Close()
+
+	var fingerprints []Fingerprint
+	for response.Next() {
+		var fingerprint Fingerprint
+		err = response.Scan(&fingerprint.File.Device, &fingerprint.File.Inode, &fingerprint.Path, &fingerprint.MinHash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fingerprint: %w", err)
+		}
+		fingerprints = append(fingerprints, fingerprint)
+	}
+	if err = response.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate through db response: %w", err)
+	}
+
+	return fingerprints, nil
+}
+
+// GetResumableScan returns the latest full scan if it never completed.
+func GetResumableScan(con *sql.DB) (int64, time.Time, error) {
+	var scanID int64
+	var scanStart string
+	var completed bool
+	query := `select scan_id, scan_start, coalesce(indexing_completed, false) from full_scans order by scan_id desc limit 1`
+	err := con.QueryRow(query).Scan(&scanID, &scanStart, &completed)
+	if err == sql.ErrNoRows || (err == nil && completed) {
+		return 0, time.Time{}, fmt.Errorf("there is no interrupted full scan to resume")
+	} else if err != nil {
+		return 0, time.Time{}, fmt.Errorf("failed to query the last full scan: %w", err)
+	}
+
+	// scan_start is a text column, so the driver hands back the timestamp the way it stored it
+	start, err := time.Parse("2006-01-02 15:04:05.999999999-07:00", scanStart)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("failed to read start of scan %d: %w", scanID, err)
+	}
+
+	return scanID, start, nil
+}
+
+func GetCheckpoints(con *sql.DB, scanID int64) ([]string, error) {
+	response, err := con.Query(`select directory from scan_checkpoints where scan_id = ?`, scanID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query scan checkpoints: %w", err)
+	}
+	defer response.Close()
+
+	var directories []string
+	for response.Next() {
+		var directory string
+		err = response.Scan(&directory)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read scan checkpoint: %w", err)
+		}
+		directories = append(directories, directory)
+	}
+	if err = response.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate through db response: %w", err)
+	}
+
+	return directories, nil
+}
+
+// ForEachIndexedEntry streams the entries of a generation, so the whole index never has to be in
+// memory at once.
+func ForEachIndexedEntry(con *sql.DB, tables Tables, handle func(entry IndexedEntry) error) error {
+	query := `select entry_id, path, is_dir, coalesce(length(full_text), 0) > 0 from ` + tables.Entries
+	response, err := con.Query(query)
+	if err != nil {
+		return fmt.Errorf("failed to query indexed entries: %w", err)
+	}
+	defer response.Close()
+
+	for response.Next() {
+		var entry IndexedEntry
+		err = response.Scan(&entry.EntryID, &entry.Path, &entry.IsDir, &entry.HasContent)
+		if err != nil {
+			return fmt.Errorf("failed to read indexed entry: %w", err)
+		}
+		err = handle(entry)
+		if err != nil {
+			return err
+		}
+	}
+	if err = response.Err(); err != nil {
+		return fmt.Errorf("failed to iterate through db response: %w", err)
+	}
+
+	return nil
+}
+
+func GetNotRegisteredEntries(con *sql.DB, tables Tables) ([]NotAccessedPaths, error) {
+	response, err := con.Query(`select path, coalesce(error, ''), coalesce(error_class, '') from ` + tables.Ignored)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ignored entries: %w", err)
+	}
+	defer response.Close()
+
+	var entries []NotAccessedPaths
+	for response.Next() {
+		var entry NotAccessedPaths
+		err = response.Scan(&entry.Path, &entry.Err, &entry.Class)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ignored entry: %w", err)
 		}
-		inodeMappedEntries[inode] = details
+		entries = append(entries, entry)
 	}
 	if err = response.Err(); err != nil {
-		return inodeMappedEntries, fmt.Errorf("failed to iterate through db response: %v", err)
+		return nil, fmt.Errorf("failed to iterate through db response: %w", err)
 	}
 
-	return inodeMappedEntries, nil
+	return entries, nil
 }
@@ -14,12 +14,13 @@ type CollectedInfo struct {
 	NumOfDirectories      int
 	NumOfFilesWithContent int
 	NumOfIgnoredEntries   int
-	EntryDetails          []*EntryCollection
-	NotRegistered         []*NotAccessedPaths
+	IgnoredByClass        map[string]int // ignored entries per error class, for the summary after a scan
 	Mu                    sync.Mutex
 }
 
 type EntryCollection struct {
+	EntryID     int64  // row of the entry, set once it is written or when an indexed entry is updated
+	Device      uint64 // st_dev of the file system holding the inode, archive members share their archive's
 	Inode       uint64
 	FullPath    string
 	ParentDirID string
@@ -38,30 +39,139 @@ type EntryCollection struct {
 	FullTextIndex        []byte // the complete textual content of a document, stored in separate Full-Text Search index
 	LineCountTotal       int
 	LineCountWithContent int
+	Title                string            // title found by the content extractor
+	Metadata             map[string]string // structured metadata found by the content extractor
+	ArchivePath          string            // path of the containing archive for virtual archive members
+	Symbols              []Symbol          // declarations found in Go sources
+	ContentHash          string            // hex SHA-256 of the content, only computed for files sharing their size with another
+	MinHash              []byte            // MinHash signature of the extracted text for near-duplicate detection
+	EntryType            string            // file, dir, symlink, fifo, socket, block_device or char_device, from lstat
+	LinkTarget           string            // target of a symlink as stored in the link
+	Nlink                uint64            // number of hard links to the inode, each indexed path is an entry of its own
 	//tags               []string // user defined tags or keywords from internal metadata
 }
 
+// ID returns the identity of the file behind the entry.
+func (e *EntryCollection) ID() FileID {
+	return FileID{Device: e.Device, Inode: e.Inode}
+}
+
 type NotAccessedPaths struct {
-	Path string
-	Err  string
+	Path       string
+	Err        string
+	Class      string // permission, vanished, io, too_large, decode or binary
+	RecordedAt time.Time
+}
+
+// IndexedEntry is the part of a written entry a resumed full scan needs to decide whether to keep it.
+type IndexedEntry struct {
+	EntryID    int64
+	Path       string
+	IsDir      bool
+	HasContent bool
 }
 
+// SyncJob asks a sync reader to read Path. An indexed path is updated in place, EntryID is its row.
 type SyncJob struct {
 	Path            string
+	EntryID         int64
 	IsIndexed       bool
 	IsContentChange bool
 }
 
+// WriteJob carries a read entry to the sync writer. Entry is nil when the path could not be read
+// at all, the reasons are in Failures. A job with ExpandedArchive set clears the indexed members
+// of that archive, its new members follow as jobs of their own. A job with Deleted set removes the
+// entry of a path that no longer exists.
+type WriteJob struct {
+	SyncJob         SyncJob
+	Entry           *EntryCollection
+	ExpandedArchive string
+	Deleted         *InodeHeader
+	Failures        []*NotAccessedPaths
+}
+
+// FileID identifies a file across file systems. Inode numbers are only unique within one device,
+// so the device is part of the identity.
+type FileID struct {
+	Device uint64
+	Inode  uint64
+}
+
 type InodeHeader struct {
+	EntryID            int64
+	Device             uint64
+	Inode              uint64
+	IsDir              bool
 	Path               string
+	Size               int64
 	ModificationTime   time.Time
 	MetaDataChangeTime time.Time
 }
 
+// ID returns the identity of the indexed file.
+func (h InodeHeader) ID() FileID {
+	return FileID{Device: h.Device, Inode: h.Inode}
+}
+
 type SearchResult struct {
 	Path             string
+	ParentDirectory  string
 	Name             string
 	Size             int64
 	ModificationTime time.Time
-	Inode            uint64
+	AccessTime       time.Time
+	EntryID          int64
+	Rank             float64 // bm25 score, negated fuzzy score or negated blended score. lower is more relevant
+	Matches          []ContentMatch
+}
+
+type ContentMatch struct {
+	Line       int      // 1-based line number
+	Column     int      // 1-based character column of the first highlighted term
+	Excerpt    string   // the matched line, shortened around the match
+	Highlights [][2]int // start and end character offsets of matched terms within Excerpt
+}
+
+type ContentFilter struct {
+	PathPrefix string
+	Extensions []string
+	Contains   string // literal every returned document has to contain, checked by sqlite
+}
+
+type SavedSearch struct {
+	Name      string
+	Query     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type EntryTag struct {
+	Name          string
+	InheritedFrom string // path of the tagged directory, empty when the tag is set on the entry itself
+}
+
+type Symbol struct {
+	EntryID  int64
+	Path     string
+	Name     string
+	Kind     string // func, method, type, const or var
+	Package  string
+	Receiver string // receiver type of a method as written, e.g. *Server
+	Line     int
+	Doc      string
+}
+
+type DuplicateCandidate struct {
+	File        FileID
+	Path        string
+	Size        int64
+	ContentHash string
+	ArchivePath string
+}
+
+type Fingerprint struct {
+	File    FileID
+	Path    string
+	MinHash []byte
 }
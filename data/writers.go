@@ -2,242 +2,187 @@ package data
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"time"
 )
 
-func checkTableExists(con *sql.DB, tableName string) (bool, error) {
-	query := `SELECT name FROM sqlite_master WHERE type='table' AND name=?`
-
-	row := con.QueryRow(query, tableName)
+// encodeMetadata stores extractor metadata as a JSON object, entries without any stay null.
+func encodeMetadata(metadata map[string]string) (any, error) {
+	if len(metadata) == 0 {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode metadata: %w", err)
+	}
 
-	var name string
-	err := row.Scan(&name)
+	return string(encoded), nil
+}
 
-	switch {
-	case err == sql.ErrNoRows:
-		return false, nil
-	case err != nil:
-		return false, fmt.Errorf("checkTableExists error: %w", err)
-	default:
-		return true, nil
+// StartScanRecord logs a full scan as soon as it starts, so an interrupted scan can be resumed.
+// indexing_completed stays false until CompleteScanRecord.
+func StartScanRecord(con *sql.DB, scanStart time.Time) (int64, error) {
+	query := `insert into full_scans(scan_start, indexing_completed) values (?, false)`
+	result, err := con.Exec(query, scanStart)
+	if err != nil {
+		return 0, fmt.Errorf("could not write entry to database: %s\n%w", query, err)
+	}
+	scanID, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("could not read id of scan record: %w", err)
 	}
+
+	return scanID, nil
 }
 
-func ClearExistingData(con *sql.DB) error {
-	entriesExist, err := checkTableExists(con, "entries")
+func CompleteScanRecord(con *sql.DB, scanID int64, theWorks *CollectedInfo) error {
+	query := `update full_scans
+			  set
+				  scan_end = ?,
+				  scan_duration = ?,
+				  directory_count = ?,
+				  file_count = ?,
+				  file_w_content_count = ?,
+				  ignored_entries_count = ?,
+				  indexing_completed = ?
+			  where scan_id = ?`
+
+	_, err := con.Exec(
+		query,
+		theWorks.ScanEnd,
+		theWorks.ScanDuration,
+		theWorks.NumOfDirectories,
+		theWorks.NumOfFiles,
+		theWorks.NumOfFilesWithContent,
+		theWorks.NumOfIgnoredEntries,
+		theWorks.IndexingCompleted,
+		scanID)
 	if err != nil {
-		return err
-	} else if entriesExist {
-		query := `delete from entries;`
-		_, err = con.Exec(query)
-		if err != nil {
-			return fmt.Errorf("could not clear existing data with query: %s\n%w", query, err)
-		}
+		return fmt.Errorf("could not update scan record: %s\n%w", query, err)
 	}
 
-	ignoredEntriesExist, err := checkTableExists(con, "entries")
+	return nil
+}
+
+// DeleteScanCheckpoints drops the checkpoints of every scan except keepScanID, since only the
+// latest scan can be resumed. A keepScanID of 0 drops all of them.
+func DeleteScanCheckpoints(con *sql.DB, keepScanID int64) error {
+	_, err := con.Exec(`delete from scan_checkpoints where scan_id != ?`, keepScanID)
 	if err != nil {
-		return err
-	} else if ignoredEntriesExist {
-		query := `delete from ignored_entries;`
-		_, err = con.Exec(query)
-		if err != nil {
-			return fmt.Errorf("could not clear existing data with query: %s\n%w", query, err)
-		}
+		return fmt.Errorf("could not delete scan checkpoints: %w", err)
 	}
 
 	return nil
 }
 
-func WriteFullEntries(con *sql.DB, entryCollection []*EntryCollection) error {
-	query := `insert into entries(
-                    inode,
-                    path,
-					parent_directory,
-					name,
-					is_dir,
-					size,
-					modification_time,
-					access_time,
-					metadata_change_time,
-					owner_id,
-					group_id,
-					extension,
-					filetype,
-					content_snippet,
-					full_text,
-                    line_count_total,
-                    line_count_w_content)
-					values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
-
-	for _, entry := range entryCollection {
-		_, err := con.Exec(
-			query,
-			entry.Inode,
-			entry.FullPath,
-			entry.ParentDirID,
-			entry.Name,
-			entry.IsDir,
-			entry.Size,
-			entry.ModificationTime,
-			entry.AccessTime,
-			entry.MetaDataChangeTime,
-			entry.OwnerID,
-			entry.GroupID,
-			entry.Extension,
-			entry.FileType,
-			entry.ContentSnippet,
-			entry.FullTextIndex,
-			entry.LineCountTotal,
-			entry.LineCountWithContent)
-		if err != nil {
-			return fmt.Errorf("could not write entry %s to database: \n%w", entry.FullPath, err)
-		}
-		fmt.Println("Wrote entry successfully:", entry.FullPath)
+func nullable(value string) any {
+	if value == "" {
+		return nil
+	}
+
+	return value
+}
+
+// nullableLinks leaves the link count of archive members empty, they have no inode of their own.
+func nullableLinks(nlink uint64) any {
+	if nlink == 0 {
+		return nil
+	}
+
+	return int64(nlink)
+}
+
+func WriteSavedSearch(con *sql.DB, name string, searchQuery string) error {
+	query := `insert into saved_searches(name, query, created_at, updated_at) values (?, ?, ?, ?)`
+	now := time.Now()
+	_, err := con.Exec(query, name, searchQuery, now, now)
+	if err != nil {
+		return fmt.Errorf("could not save search %q: %w", name, err)
 	}
 
 	return nil
 }
 
-func UpdateEntriesWithContent(con *sql.DB, entryCollection []*EntryCollection) error {
-	query := `update entries
-    		  set 
-                  path = ?,
-				  parent_directory = ?,
-				  name = ?,
-				  is_dir = ?,
-				  size = ?,
-				  modification_time = ?,
-				  access_time = ?,
-				  metadata_change_time = ?,
-				  owner_id = ?,
-				  group_id = ?,
-				  extension = ?,
-				  filetype = ?,
-				  content_snippet = ?,
-				  full_text = ?,
-                  line_count_total = ?,
-                  line_count_w_content = ?
-			  where inode = ?`
-	for _, entry := range entryCollection {
-		_, err := con.Exec(
-			query,
-			entry.FullPath,
-			entry.ParentDirID,
-			entry.Name,
-			entry.IsDir,
-			entry.Size,
-			entry.ModificationTime,
-			entry.AccessTime,
-			entry.MetaDataChangeTime,
-			entry.OwnerID,
-			entry.GroupID,
-			entry.Extension,
-			entry.FileType,
-			entry.ContentSnippet,
-			entry.FullTextIndex,
-			entry.LineCountTotal,
-			entry.LineCountWithContent,
-			entry.Inode)
-		if err != nil {
-			return fmt.Errorf("could not update entry %s in database: \n%w", entry.FullPath, err)
-		}
-		fmt.Println("Updated entry with content successfully:", entry.FullPath)
+func UpdateSavedSearch(con *sql.DB, name string, searchQuery string) error {
+	query := `update saved_searches set query = ?, updated_at = ? where name = ?`
+	result, err := con.Exec(query, searchQuery, time.Now(), name)
+	if err != nil {
+		return fmt.Errorf("could not update saved search %q: %w", name, err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return fmt.Errorf("no saved search named %q", name)
 	}
 
 	return nil
 }
 
-func UpdateEntriesWithoutContent(con *sql.DB, entryCollection []*EntryCollection) error {
-	query := `update entries
-    		  set 
-                  path = ?,
-				  parent_directory = ?,
-				  name = ?,
-				  is_dir = ?,
-				  size = ?,
-				  modification_time = ?,
-				  access_time = ?,
-				  metadata_change_time = ?,
-				  owner_id = ?,
-				  group_id = ?,
-				  extension = ?,
-				  filetype = ?
-			  where inode = ?`
-
-	for _, entry := range entryCollection {
-		_, err := con.Exec(
-			query,
-			entry.FullPath,
-			entry.ParentDirID,
-			entry.Name,
-			entry.IsDir,
-			entry.Size,
-			entry.ModificationTime,
-			entry.AccessTime,
-			entry.MetaDataChangeTime,
-			entry.OwnerID,
-			entry.GroupID,
-			entry.Extension,
-			entry.FileType,
-			entry.Inode)
-		if err != nil {
-			return fmt.Errorf("could not update entry %s in database: \n%w", entry.FullPath, err)
-		}
-		fmt.Println("Updated entry without content successfully:", entry.FullPath)
+func DeleteSavedSearch(con *sql.DB, name string) error {
+	query := `delete from saved_searches where name = ?`
+	result, err := con.Exec(query, name)
+	if err != nil {
+		return fmt.Errorf("could not delete saved search %q: %w", name, err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return fmt.Errorf("no saved search named %q", name)
 	}
 
 	return nil
 }
 
-func WriteNotRegisteredEntries(con *sql.DB, notRegistered []*NotAccessedPaths) error {
-	query := `insert into ignored_entries(path, error) values(?, ?)`
+func WriteTags(con *sql.DB, file FileID, tags []string) error {
+	for _, tag := range tags {
+		_, err := con.Exec(`insert or ignore into tags(name) values (?)`, tag)
+		if err != nil {
+			return fmt.Errorf("could not write tag %s to database: %w", tag, err)
+		}
 
-	for _, entry := range notRegistered {
-		_, err := con.Exec(query, entry.Path, entry.Err)
+		query := `insert or ignore into tagged_entries(device, inode, tag_id)
+					select ?, ?, tag_id from tags where name = ?`
+		_, err = con.Exec(query, file.Device, file.Inode, tag)
 		if err != nil {
-			return fmt.Errorf("could not write entry to database: %s\n%w", query, err)
+			return fmt.Errorf("could not tag entry %d with %s: %w", file.Inode, tag, err)
 		}
 	}
 
 	return nil
 }
 
-func WriteScanRecord(con *sql.DB, theWorks *CollectedInfo) error {
-	query := `insert into full_scans(
-                    scan_start,
-					scan_end,
-					scan_duration,
-					directory_count,
-				    file_count,
-				    file_w_content_count,
-				    ignored_entries_count,
-				    indexing_completed)
-					values (?, ?, ?, ?, ?, ?, ?, ?)`
+func DeleteTags(con *sql.DB, file FileID, tags []string) error {
+	if len(tags) == 0 {
+		_, err := con.Exec(`delete from tagged_entries where device = ? and inode = ?`, file.Device, file.Inode)
+		if err != nil {
+			return fmt.Errorf("could not remove tags from entry %d: %w", file.Inode, err)
+		}
+	}
 
-	_, err := con.Exec(
-		query,
-		theWorks.ScanStart,
-		theWorks.ScanEnd,
-		theWorks.ScanDuration,
-		theWorks.NumOfDirectories,
-		theWorks.NumOfFiles,
-		theWorks.NumOfFilesWithContent,
-		theWorks.NumOfIgnoredEntries,
-		theWorks.IndexingCompleted)
+	for _, tag := range tags {
+		query := `delete from tagged_entries
+					where device = ? and inode = ? and tag_id in (select tag_id from tags where name = ?)`
+		_, err := con.Exec(query, file.Device, file.Inode, tag)
+		if err != nil {
+			return fmt.Errorf("could not remove tag %s from entry %d: %w", tag, file.Inode, err)
+		}
+	}
+
+	// tags with inheritance turned off are kept, so the setting outlives the last use of the tag
+	query := `delete from tags where inherit = 1 and tag_id not in (select tag_id from tagged_entries)`
+	_, err := con.Exec(query)
 	if err != nil {
-		return fmt.Errorf("could not write entry to database: %s\n%w", query, err)
+		return fmt.Errorf("could not clean up unused tags: %w", err)
 	}
+
 	return nil
 }
 
-func DeleteEntry(con *sql.DB, entryPath string) error {
-	query := `delete from entries where path = ?`
-	_, err := con.Exec(query, entryPath)
+func UpdateTagInheritance(con *sql.DB, tag string, inherit bool) error {
+	result, err := con.Exec(`update tags set inherit = ? where name = ?`, inherit, tag)
 	if err != nil {
-		return fmt.Errorf("could not delete entry from database: %s\n%w", query, err)
+		return fmt.Errorf("could not update inheritance of tag %s: %w", tag, err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return fmt.Errorf("no tag named %s", tag)
 	}
-	fmt.Println("Deleted entry successfully:", entryPath)
 
 	return nil
 }
@@ -3,6 +3,8 @@ package db
 import (
 	"database/sql"
 	"fmt"
+	"os"
+	"path/filepath"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -24,3 +26,12 @@ func CloseConnection(db *sql.DB) error {
 
 	return nil
 }
+
+func GetDBPath() (string, error) {
+	homePath, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("could not resolve home directory: %w", err)
+	}
+
+	return filepath.Join(homePath, ".icu", "icu.db"), nil
+}
@@ -0,0 +1,258 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// A full scan builds a new generation of the index tables under the NextGeneration suffix while
+// searches keep reading the live ones. SwapGeneration moves it in and keeps the replaced tables
+// under the PreviousGeneration suffix, RollbackGeneration trades the live and previous tables.
+// Tags, saved searches and the scan log are not part of a generation.
+const (
+	NextGeneration     = "_next"
+	PreviousGeneration = "_previous"
+	swapGeneration     = "_swap"
+)
+
+var generationTables = []string{"entries", "entries_fts", "symbols", "ignored_entries"}
+
+// liveIndexes only exist on the live generation. The next generation is written without index
+// upkeep, and index names cannot clash between generations.
+var liveIndexes = []struct {
+	name       string
+	definition string
+}{
+	{"entries_device_inode", "entries(device, inode)"},
+	{"entries_archive_path", "entries(archive_path)"},
+	{"entries_size", "entries(size)"},
+	{"symbols_name", "symbols(name collate nocase)"},
+	{"symbols_entry_id", "symbols(entry_id)"},
+	{"ignored_entries_path", "ignored_entries(path)"},
+}
+
+// executor is satisfied by both *sql.DB and *sql.Tx, so schema steps can run inside a swap.
+type executor interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// entriesTableStatement creates an entries table. Every path is an entry of its own, hard links
+// share their device and inode, and entry_id keys the full text and symbols of the entry.
+func entriesTableStatement(table string) string {
+	return fmt.Sprintf(`create table if not exists %s (
+    		entry_id integer primary key,
+    		device int,
+    		inode int not null,
+    		path text unique,
+    		parent_directory text,
+    		name text,
+    		is_dir boolean,
+    		size int,
+    		modification_time datetime,
+    		access_time datetime,
+    		metadata_change_time datetime,
+    		owner_id int,
+    		group_id int,
+    		extension text,
+    		filetype text,
+    		content_snippet text,
+    		full_text text,
+    		line_count_total int,
+    		line_count_w_content int,
+    		title text,
+    		metadata text,
+    		archive_path text,
+    		content_hash text,
+    		minhash blob,
+    		entry_type text,
+    		link_target text,
+    		nlink int
+		);`, table)
+}
+
+func generationTableStatements(suffix string) []string {
+	return []string{
+		entriesTableStatement("entries" + suffix),
+		fmt.Sprintf(`create virtual table if not exists entries_fts%s using fts5(
+    		name,
+    		path,
+    		full_text
+		);`, suffix),
+		fmt.Sprintf(`create table if not exists symbols%s (
+    		entry_id int not null,
+    		name text not null,
+    		kind text not null,
+    		package text,
+    		receiver text,
+    		line int,
+    		doc text
+		);`, suffix),
+		fmt.Sprintf(`create table if not exists ignored_entries%s (
+    		path text,
+    		error text,
+    		error_class text,
+    		recorded_at datetime
+		);`, suffix),
+	}
+}
+
+// PrepareNextGeneration creates empty tables for a full scan to write into. Tables left behind by
+// an interrupted scan are dropped first.
+func PrepareNextGeneration(con *sql.DB) error {
+	err := dropGeneration(con, NextGeneration)
+	if err != nil {
+		return err
+	}
+
+	for _, statement := range generationTableStatements(NextGeneration) {
+		_, err = con.Exec(statement)
+		if err != nil {
+			return fmt.Errorf("could not create table %s: \n%w", statement, err)
+		}
+	}
+
+	return nil
+}
+
+// HasNextGeneration reports whether an unfinished full scan left its tables behind.
+func HasNextGeneration(con *sql.DB) (bool, error) {
+	return tableExists(con, "entries"+NextGeneration)
+}
+
+// SwapGeneration makes the next generation live in a single transaction, so readers see either the
+// old or the new index and a failed scan never leaves a partial one behind.
+func SwapGeneration(con *sql.DB) error {
+	tx, err := con.Begin()
+	if err != nil {
+		return fmt.Errorf("could not begin generation swap: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = dropGeneration(tx, PreviousGeneration)
+	if err != nil {
+		return err
+	}
+	err = dropIndexes(tx)
+	if err != nil {
+		return err
+	}
+	err = renameGeneration(tx, "", PreviousGeneration)
+	if err != nil {
+		return err
+	}
+	err = renameGeneration(tx, NextGeneration, "")
+	if err != nil {
+		return err
+	}
+	err = createIndexes(tx)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("could not commit generation swap: %w", err)
+	}
+
+	return nil
+}
+
+// RollbackGeneration trades the live tables for the ones replaced by the last full scan. Rolling
+// back twice restores the newer generation.
+func RollbackGeneration(con *sql.DB) error {
+	exists, err := tableExists(con, "entries"+PreviousGeneration)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("there is no previous index to roll back to")
+	}
+
+	tx, err := con.Begin()
+	if err != nil {
+		return fmt.Errorf("could not begin rollback: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = dropIndexes(tx)
+	if err != nil {
+		return err
+	}
+	err = renameGeneration(tx, "", swapGeneration)
+	if err != nil {
+		return err
+	}
+	err = renameGeneration(tx, PreviousGeneration, "")
+	if err != nil {
+		return err
+	}
+	err = renameGeneration(tx, swapGeneration, PreviousGeneration)
+	if err != nil {
+		return err
+	}
+	// the restored tables may predate columns added since they were live
+	err = addMissingColumns(tx)
+	if err != nil {
+		return err
+	}
+	err = fillDevices(tx, "entries")
+	if err != nil {
+		return err
+	}
+	err = createIndexes(tx)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("could not commit rollback: %w", err)
+	}
+
+	return nil
+}
+
+func dropGeneration(db executor, suffix string) error {
+	for _, table := range generationTables {
+		_, err := db.Exec(fmt.Sprintf("drop table if exists %s%s", table, suffix))
+		if err != nil {
+			return fmt.Errorf("could not drop table %s%s: %w", table, suffix, err)
+		}
+	}
+
+	return nil
+}
+
+func renameGeneration(db executor, fromSuffix string, toSuffix string) error {
+	for _, table := range generationTables {
+		statement := fmt.Sprintf("alter table %s%s rename to %s%s", table, fromSuffix, table, toSuffix)
+		_, err := db.Exec(statement)
+		if err != nil {
+			return fmt.Errorf("could not rename table %s%s: %w", table, fromSuffix, err)
+		}
+	}
+
+	return nil
+}
+
+func dropIndexes(db executor) error {
+	for _, index := range liveIndexes {
+		_, err := db.Exec(fmt.Sprintf("drop index if exists %s", index.name))
+		if err != nil {
+			return fmt.Errorf("could not drop index %s: %w", index.name, err)
+		}
+	}
+
+	return nil
+}
+
+func tableExists(db executor, table string) (bool, error) {
+	response, err := db.Query(`select name from sqlite_master where type = 'table' and name = ?`, table)
+	if err != nil {
+		return false, fmt.Errorf("could not look up table %s: %w", table, err)
+	}
+	defer response.Close()
+
+	return response.Next(), response.Err()
+}
@@ -3,7 +3,10 @@ package db
 import (
 	"database/sql"
 	"fmt"
+	"os"
 	"path/filepath"
+	"strings"
+	"syscall"
 )
 
 type DefaultConfig struct{}
@@ -16,11 +19,412 @@ func InitializeDB(servicePath string) error {
 	}
 	defer CloseConnection(db)
 
+	err = requireFTS5(db)
+	if err != nil {
+		return err
+	}
+
+	legacyTags, err := renameLegacyTagTable(db)
+	if err != nil {
+		return err
+	}
+
 	err = createTables(db)
 	if err != nil {
 		return err
 	}
 
+	err = addMissingColumns(db)
+	if err != nil {
+		return err
+	}
+
+	err = migrateEntryIDs(db)
+	if err != nil {
+		return err
+	}
+
+	err = migrateDevices(db)
+	if err != nil {
+		return err
+	}
+
+	err = createIndexes(db)
+	if err != nil {
+		return err
+	}
+
+	if legacyTags {
+		err = migrateLegacyTags(db)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addMissingColumns brings tables created by an older version up to date, since
+// "create table if not exists" leaves existing tables untouched.
+func addMissingColumns(db executor) error {
+	columns := []struct {
+		table      string
+		column     string
+		definition string
+	}{
+		{"tags", "inherit", "boolean not null default 1"},
+		{"entries", "title", "text"},
+		{"entries", "metadata", "text"},
+		{"entries", "archive_path", "text"},
+		{"entries", "content_hash", "text"},
+		{"entries", "minhash", "blob"},
+		{"entries", "entry_type", "text"},
+		{"entries", "link_target", "text"},
+		{"entries", "nlink", "int"},
+		{"entries", "device", "int"},
+		{"ignored_entries", "error_class", "text"},
+		{"ignored_entries", "recorded_at", "datetime"},
+	}
+
+	for _, column := range columns {
+		exists, err := hasColumn(db, column.table, column.column)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+
+		statement := fmt.Sprintf("alter table %s add column %s %s", column.table, column.column, column.definition)
+		_, err = db.Exec(statement)
+		if err != nil {
+			return fmt.Errorf("could not add column %s.%s: %w", column.table, column.column, err)
+		}
+	}
+
+	return nil
+}
+
+func hasColumn(db executor, table string, column string) (bool, error) {
+	response, err := db.Query(`select name from pragma_table_info(?)`, table)
+	if err != nil {
+		return false, fmt.Errorf("could not read columns of %s: %w", table, err)
+	}
+	defer response.Close()
+
+	for response.Next() {
+		var name string
+		err = response.Scan(&name)
+		if err != nil {
+			return false, fmt.Errorf("could not read columns of %s: %w", table, err)
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+
+	return false, response.Err()
+}
+
+// migrateEntryIDs rebuilds entries tables keyed by inode, which could not hold hard links, with an
+// entry_id key. The old inode becomes the entry_id, so the full text rows keep their rowids and the
+// symbols only need their column renamed. The tables of an interrupted full scan are dropped, that
+// scan has to start over.
+func migrateEntryIDs(con *sql.DB) error {
+	exists, err := tableExists(con, "entries"+NextGeneration)
+	if err != nil {
+		return err
+	}
+	if exists {
+		migrated, err := hasColumn(con, "entries"+NextGeneration, "entry_id")
+		if err != nil {
+			return err
+		}
+		if !migrated {
+			err = dropGeneration(con, NextGeneration)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	tx, err := con.Begin()
+	if err != nil {
+		return fmt.Errorf("could not begin entry id migration: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, suffix := range []string{"", PreviousGeneration} {
+		err = migrateGenerationEntryIDs(tx, suffix)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec(`drop index if exists symbols_inode`)
+	if err != nil {
+		return fmt.Errorf("could not drop index symbols_inode: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("could not commit entry id migration: %w", err)
+	}
+
+	return nil
+}
+
+func migrateGenerationEntryIDs(db executor, suffix string) error {
+	table := "entries" + suffix
+	exists, err := tableExists(db, table)
+	if err != nil || !exists {
+		return err
+	}
+	migrated, err := hasColumn(db, table, "entry_id")
+	if err != nil || migrated {
+		return err
+	}
+
+	response, err := db.Query(`select name from pragma_table_info(?)`, table)
+	if err != nil {
+		return fmt.Errorf("could not read columns of %s: %w", table, err)
+	}
+	var columns []string
+	for response.Next() {
+		var name string
+		err = response.Scan(&name)
+		if err != nil {
+			response.Close()
+			return fmt.Errorf("could not read columns of %s: %w", table, err)
+		}
+		columns = append(columns, name)
+	}
+	response.Close()
+	if err = response.Err(); err != nil {
+		return fmt.Errorf("could not read columns of %s: %w", table, err)
+	}
+
+	migrating := table + "_migrating"
+	statements := []string{
+		entriesTableStatement(migrating),
+		fmt.Sprintf("insert into %s(entry_id, %s) select inode, %s from %s",
+			migrating, strings.Join(columns, ", "), strings.Join(columns, ", "), table),
+		fmt.Sprintf("drop table %s", table),
+		fmt.Sprintf("alter table %s rename to %s", migrating, table),
+		fmt.Sprintf("alter table symbols%s rename column inode to entry_id", suffix),
+	}
+	for _, statement := range statements {
+		_, err = db.Exec(statement)
+		if err != nil {
+			return fmt.Errorf("could not migrate %s to entry ids: %s\n%w", table, statement, err)
+		}
+	}
+
+	return nil
+}
+
+// taggedEntriesTableStatement creates the table tags are set in. Tags belong to the file rather
+// than to a path, so every hard link carries them.
+func taggedEntriesTableStatement(table string) string {
+	return fmt.Sprintf(`create table if not exists %s (
+    		device int not null,
+    		inode int not null,
+    		tag_id int not null references tags(tag_id),
+    		primary key (device, inode, tag_id)
+		) without rowid;`, table)
+}
+
+// migrateDevices fills in the device of entries indexed before devices were recorded, and keys the
+// tags of such databases by device as well. Tags whose file is no longer indexed keep device 0.
+func migrateDevices(con *sql.DB) error {
+	tx, err := con.Begin()
+	if err != nil {
+		return fmt.Errorf("could not begin device migration: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = fillDevices(tx, "entries")
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`drop index if exists entries_inode`)
+	if err != nil {
+		return fmt.Errorf("could not drop index entries_inode: %w", err)
+	}
+
+	migrated, err := hasColumn(tx, "tagged_entries", "device")
+	if err != nil {
+		return err
+	}
+	if !migrated {
+		statements := []string{
+			taggedEntriesTableStatement("tagged_entries_migrating"),
+			`insert or ignore into tagged_entries_migrating(device, inode, tag_id)
+				select coalesce((select e.device from entries e where e.inode = te.inode and e.device is not null limit 1), 0),
+					te.inode, te.tag_id
+				from tagged_entries te`,
+			`drop table tagged_entries`,
+			`alter table tagged_entries_migrating rename to tagged_entries`,
+			`create index if not exists tagged_entries_tag_id on tagged_entries(tag_id)`,
+		}
+		for _, statement := range statements {
+			_, err = tx.Exec(statement)
+			if err != nil {
+				return fmt.Errorf("could not migrate tags to devices: %s\n%w", statement, err)
+			}
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("could not commit device migration: %w", err)
+	}
+
+	return nil
+}
+
+// fillDevices stats the entries of table that have no device yet. An entry only takes the device
+// of its path when the inode still matches, archive members take the device of their archive.
+func fillDevices(db executor, table string) error {
+	query := fmt.Sprintf(`select entry_id, path, inode from %s where device is null and archive_path is null`, table)
+	response, err := db.Query(query)
+	if err != nil {
+		return fmt.Errorf("could not read entries without device from %s: %w", table, err)
+	}
+	devices := make(map[int64]uint64)
+	for response.Next() {
+		var entryID int64
+		var path string
+		var inode uint64
+		err = response.Scan(&entryID, &path, &inode)
+		if err != nil {
+			response.Close()
+			return fmt.Errorf("could not read entries without device from %s: %w", table, err)
+		}
+		// a followed symlink is stored with the inode of the directory it leads to
+		for _, stat := range []func(string) (os.FileInfo, error){os.Lstat, os.Stat} {
+			info, err := stat(path)
+			if err != nil {
+				break
+			}
+			statT := info.Sys().(*syscall.Stat_t)
+			if statT.Ino == inode {
+				devices[entryID] = uint64(statT.Dev)
+				break
+			}
+		}
+	}
+	response.Close()
+	if err = response.Err(); err != nil {
+		return fmt.Errorf("could not read entries without device from %s: %w", table, err)
+	}
+
+	for entryID, device := range devices {
+		_, err = db.Exec(fmt.Sprintf(`update %s set device = ? where entry_id = ?`, table), device, entryID)
+		if err != nil {
+			return fmt.Errorf("could not store device of entry %d: %w", entryID, err)
+		}
+	}
+
+	query = fmt.Sprintf(`update %[1]s set device = (select a.device from %[1]s a where a.path = %[1]s.archive_path)
+				where device is null and archive_path is not null`, table)
+	_, err = db.Exec(query)
+	if err != nil {
+		return fmt.Errorf("could not store devices of archive members in %s: %w", table, err)
+	}
+
+	return nil
+}
+
+// requireFTS5 fails early when SQLite was compiled without FTS5, which go-sqlite3 only includes
+// with the sqlite_fts5 build tag, instead of leaving setup to stop halfway at the full text table.
+func requireFTS5(db *sql.DB) error {
+	var enabled bool
+	err := db.QueryRow(`select sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&enabled)
+	if err != nil {
+		return fmt.Errorf("could not check for the FTS5 module: %w", err)
+	}
+	if !enabled {
+		return fmt.Errorf("SQLite was built without the FTS5 module, rebuild icu with go build -tags sqlite_fts5")
+	}
+
+	return nil
+}
+
+// renameLegacyTagTable moves the original tagged_entries(inode, tags) table out of the way so the
+// normalized tag tables can be created in its place.
+func renameLegacyTagTable(db *sql.DB) (bool, error) {
+	legacy, err := hasColumn(db, "tagged_entries", "tags")
+	if err != nil || !legacy {
+		return false, err
+	}
+
+	_, err = db.Exec(`alter table tagged_entries rename to tagged_entries_legacy`)
+	if err != nil {
+		return false, fmt.Errorf("could not rename legacy tag table: %w", err)
+	}
+
+	return true, nil
+}
+
+func migrateLegacyTags(db *sql.DB) error {
+	response, err := db.Query(`select inode, coalesce(tags, '') from tagged_entries_legacy`)
+	if err != nil {
+		return fmt.Errorf("could not read legacy tags: %w", err)
+	}
+
+	legacyTags := make(map[uint64][]string)
+	for response.Next() {
+		var inode uint64
+		var tags string
+		err = response.Scan(&inode, &tags)
+		if err != nil {
+			response.Close()
+			return fmt.Errorf("could not read legacy tags: %w", err)
+		}
+		legacyTags[inode] = strings.FieldsFunc(tags, func(r rune) bool {
+			return r == ',' || r == ' '
+		})
+	}
+	response.Close()
+
+	for inode, tags := range legacyTags {
+		for _, tag := range tags {
+			tag = strings.ToLower(strings.TrimSpace(tag))
+			if tag == "" {
+				continue
+			}
+			_, err = db.Exec(`insert or ignore into tags(name) values (?)`, tag)
+			if err != nil {
+				return fmt.Errorf("could not migrate tag %s: %w", tag, err)
+			}
+			_, err = db.Exec(`insert or ignore into tagged_entries(device, inode, tag_id)
+								select coalesce((select device from entries where inode = ? and device is not null limit 1), 0), ?, tag_id
+								from tags where name = ?`, inode, inode, tag)
+			if err != nil {
+				return fmt.Errorf("could not migrate tag %s: %w", tag, err)
+			}
+		}
+	}
+
+	_, err = db.Exec(`drop table tagged_entries_legacy`)
+	if err != nil {
+		return fmt.Errorf("could not drop legacy tag table: %w", err)
+	}
+
+	return nil
+}
+
+// createIndexes runs after addMissingColumns, so indexes may cover columns that older databases
+// only just received.
+func createIndexes(db executor) error {
+	for _, index := range liveIndexes {
+		statement := fmt.Sprintf("create index if not exists %s on %s;", index.name, index.definition)
+		_, err := db.Exec(statement)
+		if err != nil {
+			return fmt.Errorf("could not create index %s: \n%w", statement, err)
+		}
+	}
+
 	return nil
 }
 
@@ -37,35 +441,28 @@ func createTables(db *sql.DB) error {
          	ignored_entries_count int,
          	indexing_completed bool
          );`,
-		`create table if not exists entries (
-    		inode int not null primary key,
-    		path text unique,
-    		parent_directory text,
-    		name text,
-    		is_dir boolean,
-    		size int,
-    		modification_time datetime,
-    		access_time datetime,
-    		metadata_change_time datetime,
-    		owner_id int,
-    		group_id int,
-    		extension text,
-    		filetype text,
-    		content_snippet text,
-    		full_text text,
-    		line_count_total int,
-    		line_count_w_content int
+		`create table if not exists scan_checkpoints (
+    		scan_id int not null references full_scans(scan_id),
+    		directory text not null,
+    		primary key (scan_id, directory)
 		) without rowid;`,
-		`create table if not exists tagged_entries (
-    		inode int not null primary key,
-    		tags text
+		`create table if not exists tags (
+    		tag_id integer primary key,
+    		name text not null unique,
+    		inherit boolean not null default 1
 		);`,
-		`create table if not exists ignored_entries (
-    		path text,
-    		error text
+		taggedEntriesTableStatement("tagged_entries"),
+		`create index if not exists tagged_entries_tag_id on tagged_entries(tag_id);`,
+		`create table if not exists saved_searches (
+    		name text not null primary key,
+    		query text not null,
+    		created_at datetime,
+    		updated_at datetime
 		);`,
 	}
 
+	tableStatements = append(tableStatements, generationTableStatements("")...)
+
 	for _, statement := range tableStatements {
 		_, err := db.Exec(statement)
 		if err != nil {
@@ -0,0 +1,208 @@
+package dupes
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"icu/config"
+	"icu/data"
+	"icu/db"
+	"icu/utils"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+const hashWorkers = 8
+
+var groupStyle = lipgloss.NewStyle().Bold(true)
+
+type Group struct {
+	ContentHash string
+	Size        int64
+	Paths       []string
+	Copies      int // distinct files, hard links of one file share its storage
+}
+
+func (g Group) Wasted() int64 {
+	return g.Size * int64(g.Copies-1)
+}
+
+// Find groups files with identical content. Only files sharing their size with another one are
+// considered, and those still missing a hash are hashed now and the hash stored for next time.
+func Find(con *sql.DB, minSize int64, pathPrefix string, batchSize int) ([]Group, error) {
+	candidates, err := data.GetDuplicateCandidates(con, minSize, pathPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	err = FillMissingHashes(con, candidates, batchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	byHash := make(map[string]*Group)
+	files := make(map[string]map[data.FileID]bool)
+	for _, candidate := range candidates {
+		if candidate.ContentHash == "" {
+			continue
+		}
+		// the size is part of the key, so a hash collision across sizes cannot merge groups
+		key := fmt.Sprintf("%d:%s", candidate.Size, candidate.ContentHash)
+		group, ok := byHash[key]
+		if !ok {
+			group = &Group{ContentHash: candidate.ContentHash, Size: candidate.Size}
+			byHash[key] = group
+			files[key] = make(map[data.FileID]bool)
+		}
+		group.Paths = append(group.Paths, candidate.Path)
+		if !files[key][candidate.File] {
+			files[key][candidate.File] = true
+			group.Copies++
+		}
+	}
+
+	var groups []Group
+	for _, group := range byHash {
+		if group.Copies < 2 {
+			continue
+		}
+		sort.Strings(group.Paths)
+		groups = append(groups, *group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Wasted() != groups[j].Wasted() {
+			return groups[i].Wasted() > groups[j].Wasted()
+		}
+		return groups[i].Paths[0] < groups[j].Paths[0]
+	})
+
+	return groups, nil
+}
+
+// FillMissingHashes hashes the candidates without a content hash and stores the hashes in batches
+// of batchSize. Archive members are skipped, they can only be hashed while their archive is expanded.
+func FillMissingHashes(con *sql.DB, candidates []data.DuplicateCandidate, batchSize int) error {
+	jobs := make(chan *data.DuplicateCandidate)
+	hashed := make(chan *data.DuplicateCandidate, hashWorkers)
+	var wg sync.WaitGroup
+	wg.Add(hashWorkers)
+	for i := 0; i < hashWorkers; i++ {
+		go func() {
+			defer wg.Done()
+			for candidate := range jobs {
+				contentHash, err := HashFile(candidate.Path)
+				if err != nil {
+					fmt.Println(err)
+					continue
+				}
+				candidate.ContentHash = contentHash
+				hashed <- candidate
+			}
+		}()
+	}
+
+	go func() {
+		for i := range candidates {
+			if candidates[i].ContentHash == "" && candidates[i].ArchivePath == "" {
+				jobs <- &candidates[i]
+			}
+		}
+		close(jobs)
+		wg.Wait()
+		close(hashed)
+	}()
+
+	writer := data.NewBatchWriter(con, data.LiveTables, batchSize, false)
+	defer writer.Rollback()
+	for candidate := range hashed {
+		err := writer.UpdateContentHash(candidate.File, candidate.ContentHash)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+
+	return writer.Commit()
+}
+
+func Main(arguments []string) error {
+	flags := flag.NewFlagSet("dupes", flag.ContinueOnError)
+	flags.SetOutput(os.Stdout)
+	minSizeFlag := flags.String("min-size", "1", "ignore files smaller than this, e.g. 1MB")
+	pathPrefix := flags.String("path", "", "only consider files below this path")
+	err := flags.Parse(arguments)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return fmt.Errorf("usage: dupes [-min-size 1MB] [-path dir]")
+	}
+
+	minSize, err := utils.ParseSize(*minSizeFlag)
+	if err != nil {
+		return err
+	}
+	if minSize < 1 {
+		// every empty file would otherwise be a duplicate of every other one
+		minSize = 1
+	}
+	scope := ""
+	if *pathPrefix != "" {
+		scope, err = utils.ExpandPath(*pathPrefix)
+		if err != nil {
+			return err
+		}
+		scope, err = filepath.Abs(scope)
+		if err != nil {
+			return fmt.Errorf("could not resolve %s: %w", *pathPrefix, err)
+		}
+	}
+
+	loadedConfig, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	dbPath, err := db.GetDBPath()
+	if err != nil {
+		return err
+	}
+	con, err := db.CreateConnection(dbPath)
+	if err != nil {
+		return err
+	}
+	defer func(con *sql.DB) {
+		err = db.CloseConnection(con)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}(con)
+
+	groups, err := Find(con, minSize, scope, loadedConfig.Writing.BatchSize)
+	if err != nil {
+		return err
+	}
+	if len(groups) == 0 {
+		fmt.Println("no duplicates found")
+		return nil
+	}
+
+	var totalWasted int64
+	for _, group := range groups {
+		totalWasted += group.Wasted()
+		header := fmt.Sprintf("%d copies of %s, %s wasted", group.Copies, utils.FormatSize(group.Size), utils.FormatSize(group.Wasted()))
+		if len(group.Paths) > group.Copies {
+			header += fmt.Sprintf(", %d paths", len(group.Paths))
+		}
+		fmt.Println(groupStyle.Render(header))
+		for _, path := range group.Paths {
+			fmt.Println("  " + path)
+		}
+	}
+	fmt.Printf("%d groups, %s wasted in total\n", len(groups), utils.FormatSize(totalWasted))
+
+	return nil
+}
@@ -0,0 +1,142 @@
+package extract
+
+import (
+	"bytes"
+	"fmt"
+	"icu/utils"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+	xunicode "golang.org/x/text/encoding/unicode"
+)
+
+const (
+	// textSample is how much of a file the encoding and binary checks look at, the same amount git
+	// inspects to tell text from binary
+	textSample = 8000
+	// maxControlRatio is the share of control characters above which content is taken for binary
+	maxControlRatio = 0.1
+)
+
+// Source encodings stored in the metadata of documents that were not UTF-8.
+const (
+	encodingUTF8        = "utf-8"
+	encodingUTF16LE     = "utf-16le"
+	encodingUTF16BE     = "utf-16be"
+	encodingWindows1252 = "windows-1252"
+)
+
+var (
+	bomUTF8    = []byte{0xef, 0xbb, 0xbf}
+	bomUTF16LE = []byte{0xff, 0xfe}
+	bomUTF16BE = []byte{0xfe, 0xff}
+)
+
+// decodeText returns contents as UTF-8 together with the encoding they were read in. A byte order
+// mark decides the encoding where there is one. Without it, UTF-16 is recognised by its zero bytes,
+// valid UTF-8 is kept as is and anything else is read as Windows-1252, which covers Latin-1 as
+// well. Contents that still do not look like text are rejected with utils.ErrBinary.
+func decodeText(contents []byte) ([]byte, string, error) {
+	var text []byte
+	var encoding string
+	var err error
+	switch {
+	case bytes.HasPrefix(contents, bomUTF8):
+		text, encoding = contents[len(bomUTF8):], encodingUTF8
+	case bytes.HasPrefix(contents, bomUTF16LE):
+		text, err = decodeUTF16(contents, xunicode.LittleEndian, xunicode.ExpectBOM)
+		encoding = encodingUTF16LE
+	case bytes.HasPrefix(contents, bomUTF16BE):
+		text, err = decodeUTF16(contents, xunicode.BigEndian, xunicode.ExpectBOM)
+		encoding = encodingUTF16BE
+	default:
+		order, isUTF16 := utf16Order(contents)
+		switch {
+		case isUTF16 && order == xunicode.LittleEndian:
+			text, err = decodeUTF16(contents, order, xunicode.IgnoreBOM)
+			encoding = encodingUTF16LE
+		case isUTF16:
+			text, err = decodeUTF16(contents, order, xunicode.IgnoreBOM)
+			encoding = encodingUTF16BE
+		case utf8.Valid(contents):
+			text, encoding = contents, encodingUTF8
+		default:
+			text, err = charmap.Windows1252.NewDecoder().Bytes(contents)
+			encoding = encodingWindows1252
+		}
+	}
+	if err != nil {
+		return nil, encoding, fmt.Errorf("could not decode %s content: %w: %w", encoding, utils.ErrDecode, err)
+	}
+
+	if reason := binaryReason(text); reason != "" {
+		return nil, encoding, fmt.Errorf("%w: %s", utils.ErrBinary, reason)
+	}
+
+	return text, encoding, nil
+}
+
+// utf16Order recognises UTF-16 without a byte order mark by the zero high bytes of mostly ASCII
+// text, which are the odd bytes in little endian and the even ones in big endian. Text in other
+// scripts is only recognised with a byte order mark.
+func utf16Order(contents []byte) (xunicode.Endianness, bool) {
+	sample := contents[:min(len(contents), textSample)]
+	pairs := len(sample) / 2
+	if pairs == 0 {
+		return xunicode.LittleEndian, false
+	}
+
+	evenZeros, oddZeros := 0, 0
+	for i := 0; i+1 < len(sample); i += 2 {
+		if sample[i] == 0 {
+			evenZeros++
+		}
+		if sample[i+1] == 0 {
+			oddZeros++
+		}
+	}
+	switch {
+	case oddZeros*10 >= pairs*7 && evenZeros*100 <= pairs:
+		return xunicode.LittleEndian, true
+	case evenZeros*10 >= pairs*7 && oddZeros*100 <= pairs:
+		return xunicode.BigEndian, true
+	}
+
+	return xunicode.LittleEndian, false
+}
+
+func decodeUTF16(contents []byte, order xunicode.Endianness, bom xunicode.BOMPolicy) ([]byte, error) {
+	return xunicode.UTF16(order, bom).NewDecoder().Bytes(contents)
+}
+
+// binaryReason looks at the start of decoded text and explains why it is binary rather than
+// text, or returns "" when it reads as text. Escape characters count as text for the sake of
+// terminal logs with colours.
+func binaryReason(text []byte) string {
+	sample := text[:min(len(text), textSample)]
+	characters, controls := 0, 0
+	for len(sample) > 0 {
+		r, size := utf8.DecodeRune(sample)
+		if r == utf8.RuneError && size <= 1 && !utf8.FullRune(sample) {
+			// a character cut off by the end of the sample
+			break
+		}
+		sample = sample[size:]
+		characters++
+
+		switch {
+		case r == 0:
+			return "contains NUL characters"
+		case r == '\n' || r == '\r' || r == '\t' || r == '\f' || r == '\v' || r == 0x1b:
+		case r == utf8.RuneError || unicode.IsControl(r):
+			controls++
+		}
+	}
+
+	if characters > 0 && float64(controls) > float64(characters)*maxControlRatio {
+		return fmt.Sprintf("%d of the first %d characters are control characters", controls, characters)
+	}
+
+	return ""
+}
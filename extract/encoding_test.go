@@ -0,0 +1,156 @@
+package extract
+
+import (
+	"bytes"
+	"errors"
+	"icu/utils"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+func utf16LE(text string) []byte {
+	var out []byte
+	for _, unit := range utf16.Encode([]rune(text)) {
+		out = append(out, byte(unit), byte(unit>>8))
+	}
+	return out
+}
+
+func utf16BE(text string) []byte {
+	var out []byte
+	for _, unit := range utf16.Encode([]rune(text)) {
+		out = append(out, byte(unit>>8), byte(unit))
+	}
+	return out
+}
+
+func join(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func TestDecodeText(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents []byte
+		text     string
+		encoding string
+	}{
+		{"empty", nil, "", encodingUTF8},
+		{"ascii", []byte("hello world\n"), "hello world\n", encodingUTF8},
+		{"utf-8", []byte("grüße, 世界"), "grüße, 世界", encodingUTF8},
+		{"utf-8 bom", join(bomUTF8, []byte("grüße")), "grüße", encodingUTF8},
+		{"utf-16le bom", join(bomUTF16LE, utf16LE("grüße, 世界")), "grüße, 世界", encodingUTF16LE},
+		{"utf-16be bom", join(bomUTF16BE, utf16BE("grüße, 世界")), "grüße, 世界", encodingUTF16BE},
+		{"utf-16le bom surrogate pair", join(bomUTF16LE, utf16LE("a😀b")), "a😀b", encodingUTF16LE},
+		{"utf-16le without bom", utf16LE("plain ascii text\r\n"), "plain ascii text\r\n", encodingUTF16LE},
+		{"utf-16be without bom", utf16BE("plain ascii text\r\n"), "plain ascii text\r\n", encodingUTF16BE},
+		{"utf-16le without bom mostly ascii", utf16LE("café au lait, crème brûlée"),
+			"café au lait, crème brûlée", encodingUTF16LE},
+		{"latin-1", []byte("caf\xe9 cr\xe8me"), "café crème", encodingWindows1252},
+		{"windows-1252 punctuation", []byte("\x93quoted\x94 \x96 5\x80 \x85"), "“quoted” – 5€ …", encodingWindows1252},
+		{"windows-1252 undefined byte", []byte("a\x81 b\xe9 undefined"), "a\ufffd bé undefined", encodingWindows1252},
+		{"terminal colours", []byte("\x1b[31mred\x1b[0m\n"), "\x1b[31mred\x1b[0m\n", encodingUTF8},
+		{"form feeds and tabs", []byte("a\tb\fc\vd\r\n"), "a\tb\fc\vd\r\n", encodingUTF8},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			text, encoding, err := decodeText(test.contents)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(text) != test.text {
+				t.Errorf("text got %q, want %q", text, test.text)
+			}
+			if encoding != test.encoding {
+				t.Errorf("encoding got %q, want %q", encoding, test.encoding)
+			}
+		})
+	}
+}
+
+func TestDecodeTextBinary(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents []byte
+		encoding string
+		reason   string
+	}{
+		{"nul byte", []byte("text\x00more"), encodingUTF8, "contains NUL characters"},
+		{"nul in utf-16", join(bomUTF16LE, utf16LE("a\x00b")), encodingUTF16LE, "contains NUL characters"},
+		{"elf header", []byte("\x7fELF\x02\x01\x01\x00\x00\xb0"), encodingWindows1252, "contains NUL characters"},
+		{"control bytes", []byte("ab\x01\x02\x03\x04defgh"), encodingUTF8,
+			"4 of the first 11 characters are control characters"},
+		{"undefined windows-1252 bytes", []byte("\x81\x8d\x8f\x90\x9dabcdef\xff"), encodingWindows1252,
+			"5 of the first 12 characters are control characters"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			text, encoding, err := decodeText(test.contents)
+			if !errors.Is(err, utils.ErrBinary) {
+				t.Fatalf("got text %q and error %v, want %v", text, err, utils.ErrBinary)
+			}
+			if !strings.HasSuffix(err.Error(), test.reason) {
+				t.Errorf("error got %q, want reason %q", err, test.reason)
+			}
+			if encoding != test.encoding {
+				t.Errorf("encoding got %q, want %q", encoding, test.encoding)
+			}
+		})
+	}
+}
+
+func TestUTF16Order(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents []byte
+		isUTF16  bool
+	}{
+		{"single byte", []byte("a"), false},
+		{"ascii", []byte("plain ascii text"), false},
+		// seven of ten characters with a zero high byte is just enough
+		{"threshold", utf16LE("abcdefgжжж"), true},
+		{"below threshold", utf16LE("abcdefжжжж"), false},
+		// a zero in the other half of more than one pair in a hundred is not UTF-16
+		{"zeros on both sides", join(utf16LE(strings.Repeat("a", 98)), []byte{0, 0, 0, 0}), false},
+		{"cyrillic without bom", utf16LE("привет"), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, isUTF16 := utf16Order(test.contents); isUTF16 != test.isUTF16 {
+				t.Errorf("got %v, want %v", isUTF16, test.isUTF16)
+			}
+		})
+	}
+}
+
+func TestBinaryReason(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   []byte
+		reason string
+	}{
+		{"empty", nil, ""},
+		{"text", []byte("just text\n"), ""},
+		// one control character in ten is still text, the threshold is more than a tenth
+		{"at threshold", []byte("\x01abcdefghi"), ""},
+		{"above threshold", []byte("\x01\x02abcdefghi"), "2 of the first 11 characters are control characters"},
+		{"invalid utf-8 counts as control", []byte("\xff\xfeabc"), "2 of the first 5 characters are control characters"},
+		{"delete is a control character", []byte("\x7f\x7fabcdefgh"), "2 of the first 10 characters are control characters"},
+		{"nul wins over the ratio", []byte("abc\x00"), "contains NUL characters"},
+		// only the sample is looked at
+		{"nul after the sample", append(bytes.Repeat([]byte("a"), textSample), 0), ""},
+		{"rune cut by the sample", append(bytes.Repeat([]byte("a"), textSample-1), "é"...), ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if reason := binaryReason(test.text); reason != test.reason {
+				t.Errorf("got %q, want %q", reason, test.reason)
+			}
+		})
+	}
+}
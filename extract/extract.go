@@ -0,0 +1,166 @@
+package extract
+
+import (
+	"bytes"
+	"fmt"
+	"icu/data"
+	"icu/utils"
+	"os"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	snippetLength = 500
+	// maxContentSize caps how much of a file is read into memory for extraction, larger files are
+	// indexed by their metadata only
+	maxContentSize = 64 << 20
+)
+
+// Document is the searchable form of a file.
+type Document struct {
+	Text     []byte
+	Title    string
+	Metadata map[string]string
+}
+
+// Extractor turns the raw contents of a file into a Document. Unless it is a container extractor,
+// the contents it gets are already decoded to UTF-8 text.
+type Extractor interface {
+	Extract(path string, contents []byte) (Document, error)
+}
+
+// containerExtractor marks extractors of binary container formats such as zip based documents.
+// Their contents are passed on as read rather than decoded as text.
+type containerExtractor interface {
+	container()
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Extractor{}
+)
+
+// Register makes an extractor available for the given keys. A key is either a MIME type such as
+// "text/html", a MIME family such as "text/*" or an extension such as ".html". Registering a key
+// again replaces the previous extractor.
+func Register(extractor Extractor, keys ...string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	for _, key := range keys {
+		registry[strings.ToLower(key)] = extractor
+	}
+}
+
+// Lookup finds the extractor for a file. The exact MIME type wins over the extension, which in turn
+// wins over the MIME family.
+func Lookup(mimeType string, extension string) (Extractor, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	mimeType = strings.ToLower(mimeType)
+	candidates := []string{mimeType, strings.ToLower(extension)}
+	if family, _, found := strings.Cut(mimeType, "/"); found {
+		candidates = append(candidates, family+"/*")
+	}
+
+	for _, candidate := range candidates {
+		if candidate == "" {
+			continue
+		}
+		if extractor, ok := registry[candidate]; ok {
+			return extractor, true
+		}
+	}
+
+	return nil, false
+}
+
+func init() {
+	Register(textExtractor{}, "text/*", "application/toml", "application/yaml", "application/sql", ".txt")
+	Register(markdownExtractor{}, "text/markdown", ".md", ".markdown")
+	Register(htmlExtractor{}, "text/html", "application/xhtml+xml", ".html", ".htm", ".xhtml")
+	Register(xmlExtractor{}, "text/xml", "application/xml", ".xml")
+	Register(jsonExtractor{}, "application/json", ".json")
+	Register(csvExtractor{}, "text/csv", ".csv")
+	Register(docxExtractor{}, "application/vnd.openxmlformats-officedocument.wordprocessingml.document", ".docx")
+	Register(odtExtractor{}, "application/vnd.oasis.opendocument.text", ".odt")
+	Register(epubExtractor{}, "application/epub+zip", ".epub")
+}
+
+// Content reads the file behind entry and fills in its textual fields using the extractor
+// registered for its type. It reports false when no extractor handles the file.
+func Content(entry *data.EntryCollection) (bool, error) {
+	if _, ok := Lookup(entry.FileType, entry.Extension); !ok {
+		return false, nil
+	}
+
+	if entry.Size > maxContentSize {
+		return false, fmt.Errorf("%s is %s, content is extracted up to %s: %w",
+			entry.FullPath, utils.FormatSize(entry.Size), utils.FormatSize(maxContentSize), utils.ErrTooLarge)
+	}
+
+	contents, err := os.ReadFile(entry.FullPath)
+	if err != nil {
+		return false, fmt.Errorf("could not read %s: %w", entry.FullPath, err)
+	}
+
+	return ContentFromBytes(entry, contents)
+}
+
+// ContentFromBytes works like Content for files that are already in memory, such as archive
+// members.
+func ContentFromBytes(entry *data.EntryCollection, contents []byte) (bool, error) {
+	extractor, ok := Lookup(entry.FileType, entry.Extension)
+	if !ok {
+		return false, nil
+	}
+
+	encoding := encodingUTF8
+	if _, ok := extractor.(containerExtractor); !ok {
+		var err error
+		contents, encoding, err = decodeText(contents)
+		if err != nil {
+			return false, fmt.Errorf("skipped content of %s: %w", entry.FullPath, err)
+		}
+	}
+
+	document, err := extractor.Extract(entry.FullPath, contents)
+	if err != nil {
+		return false, fmt.Errorf("could not extract content from %s: %w: %w", entry.FullPath, utils.ErrDecode, err)
+	}
+	if encoding != encodingUTF8 {
+		if document.Metadata == nil {
+			document.Metadata = map[string]string{}
+		}
+		document.Metadata["encoding"] = encoding
+	}
+
+	text := document.Text
+	lineCountTotal := bytes.Count(text, []byte("\n"))
+	blankLines := bytes.Count(text, []byte("\n\n"))
+
+	entry.ContentSnippet = snippet(text)
+	entry.FullTextIndex = text
+	entry.LineCountTotal = lineCountTotal
+	entry.LineCountWithContent = lineCountTotal - blankLines
+	entry.Title = document.Title
+	entry.Metadata = document.Metadata
+
+	return true, nil
+}
+
+func snippet(text []byte) []byte {
+	if len(text) <= snippetLength {
+		return text
+	}
+
+	end := snippetLength
+	for end > 0 && !utf8.RuneStart(text[end]) {
+		end--
+	}
+
+	return text[:end]
+}
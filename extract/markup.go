@@ -0,0 +1,274 @@
+package extract
+
+import (
+	"bytes"
+	"encoding/xml"
+	"icu/utils"
+	"io"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// markupRules describes how the elements of an XML dialect map onto plain text. Element names are
+// compared by their lowercase local name, so namespace prefixes such as "w:" or "text:" are ignored.
+type markupRules struct {
+	html     bool
+	blockAll bool
+	blocks   map[string]bool
+	skip     map[string]bool
+	inline   map[string]string
+	title    string
+	onStart  func(element xml.StartElement, metadata map[string]string)
+}
+
+func set(names ...string) map[string]bool {
+	members := make(map[string]bool, len(names))
+	for _, name := range names {
+		members[name] = true
+	}
+
+	return members
+}
+
+var htmlRules = markupRules{
+	html: true,
+	blocks: set("address", "article", "aside", "blockquote", "br", "dd", "div", "dl", "dt", "figcaption",
+		"footer", "h1", "h2", "h3", "h4", "h5", "h6", "header", "hr", "li", "main", "nav", "ol", "p", "pre",
+		"section", "table", "td", "th", "title", "tr", "ul"),
+	skip:  set("script", "style", "noscript", "template", "svg"),
+	title: "title",
+	onStart: func(element xml.StartElement, metadata map[string]string) {
+		switch strings.ToLower(element.Name.Local) {
+		case "html":
+			if language := attribute(element, "lang"); language != "" {
+				metadata["language"] = language
+			}
+		case "meta":
+			name := attribute(element, "name")
+			if name == "" {
+				name = attribute(element, "property")
+			}
+			content := attribute(element, "content")
+			if name != "" && content != "" {
+				metadata[strings.ToLower(name)] = content
+			}
+		}
+	},
+}
+
+// generic XML has no notion of paragraphs, so every element gets its own line
+var xmlRules = markupRules{blockAll: true, title: "title"}
+
+// rawTextElements hold script and style sources whose "<" characters would otherwise be taken
+// for markup by the lenient HTML decoder.
+var rawTextElements = regexp.MustCompile(`(?is)<script\b.*?</script\s*>|<style\b.*?</style\s*>`)
+
+type htmlExtractor struct{}
+
+func (htmlExtractor) Extract(path string, contents []byte) (Document, error) {
+	return htmlRules.extract(rawTextElements.ReplaceAll(contents, nil))
+}
+
+type xmlExtractor struct{}
+
+func (xmlExtractor) Extract(path string, contents []byte) (Document, error) {
+	document, err := xmlRules.extract(contents)
+	if err != nil {
+		return Document{}, err
+	}
+	if root := rootElement(contents); root != "" {
+		document.Metadata["root"] = root
+	}
+
+	return document, nil
+}
+
+func attribute(element xml.StartElement, name string) string {
+	for _, attr := range element.Attr {
+		if strings.EqualFold(attr.Name.Local, name) {
+			return strings.TrimSpace(attr.Value)
+		}
+	}
+
+	return ""
+}
+
+func newDecoder(contents []byte, html bool) *xml.Decoder {
+	decoder := xml.NewDecoder(bytes.NewReader(contents))
+	if html {
+		decoder.Strict = false
+		decoder.AutoClose = xml.HTMLAutoClose
+		decoder.Entity = xml.HTMLEntity
+	}
+	// documents declaring another charset are read as is rather than rejected
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	return decoder
+}
+
+func (rules markupRules) extract(contents []byte) (Document, error) {
+	decoder := newDecoder(contents, rules.html)
+	document := Document{Metadata: map[string]string{}}
+
+	var text textBuilder
+	var title strings.Builder
+	inTitle := false
+	skipDepth := 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// keep whatever could be read from a malformed document
+			if text.Len() > 0 {
+				break
+			}
+			return Document{}, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			name := strings.ToLower(t.Name.Local)
+			if skipDepth > 0 || rules.skip[name] {
+				skipDepth++
+				continue
+			}
+			if replacement, ok := rules.inline[name]; ok {
+				text.WriteString(replacement)
+			}
+			if rules.blockAll || rules.blocks[name] {
+				text.newline()
+			}
+			if name == rules.title && document.Title == "" {
+				inTitle = true
+			}
+			if rules.onStart != nil {
+				rules.onStart(t, document.Metadata)
+			}
+		case xml.EndElement:
+			if skipDepth > 0 {
+				skipDepth--
+				continue
+			}
+			name := strings.ToLower(t.Name.Local)
+			if rules.blockAll || rules.blocks[name] {
+				text.newline()
+			}
+			if inTitle && name == rules.title {
+				inTitle = false
+				document.Title = strings.Join(strings.Fields(title.String()), " ")
+			}
+		case xml.CharData:
+			if skipDepth > 0 {
+				continue
+			}
+			if inTitle {
+				title.Write(t)
+			}
+			text.writeCollapsed(t)
+		}
+	}
+
+	document.Text = utils.NormalizeContent(bytes.TrimSpace(text.Bytes()))
+
+	return document, nil
+}
+
+// xmlFields collects the character data of the elements named in fields, keyed by the metadata
+// name they map to. Repeated elements are joined with a comma.
+func xmlFields(contents []byte, fields map[string]string) (map[string]string, error) {
+	decoder := newDecoder(contents, false)
+	values := map[string]string{}
+
+	current := ""
+	var value strings.Builder
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if key, ok := fields[strings.ToLower(t.Name.Local)]; ok {
+				current = key
+				value.Reset()
+			}
+		case xml.EndElement:
+			if current == "" || fields[strings.ToLower(t.Name.Local)] != current {
+				continue
+			}
+			collected := strings.Join(strings.Fields(value.String()), " ")
+			if collected != "" {
+				if values[current] != "" {
+					collected = values[current] + ", " + collected
+				}
+				values[current] = collected
+			}
+			current = ""
+		case xml.CharData:
+			if current != "" {
+				value.Write(t)
+			}
+		}
+	}
+
+	return values, nil
+}
+
+func rootElement(contents []byte) string {
+	decoder := newDecoder(contents, false)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return ""
+		}
+		if element, ok := token.(xml.StartElement); ok {
+			return element.Name.Local
+		}
+	}
+}
+
+// textBuilder collapses whitespace the way a browser would while keeping explicit line breaks.
+type textBuilder struct {
+	bytes.Buffer
+}
+
+func (b *textBuilder) last() byte {
+	if b.Len() == 0 {
+		return '\n'
+	}
+
+	return b.Bytes()[b.Len()-1]
+}
+
+func (b *textBuilder) newline() {
+	if b.last() != '\n' {
+		b.WriteByte('\n')
+	}
+}
+
+func (b *textBuilder) writeCollapsed(chunk []byte) {
+	space := false
+	for _, r := range string(chunk) {
+		if unicode.IsSpace(r) {
+			space = true
+			continue
+		}
+		if space && b.last() != '\n' && b.last() != ' ' && b.last() != '\t' {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteRune(r)
+	}
+	if space && b.last() != '\n' && b.last() != ' ' {
+		b.WriteByte(' ')
+	}
+}
@@ -0,0 +1,256 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"icu/utils"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+)
+
+var docxRules = markupRules{
+	blocks: set("p", "tr"),
+	skip:   set("instrtext", "deltext"),
+	inline: map[string]string{"tab": "\t", "br": "\n", "cr": "\n"},
+}
+
+var odtRules = markupRules{
+	blocks: set("p", "h", "list-item", "table-row"),
+	skip:   set("tracked-changes"),
+	inline: map[string]string{"tab": "\t", "line-break": "\n", "s": " "},
+}
+
+// officeFields maps the Dublin Core and ODF meta elements to metadata names.
+var officeFields = map[string]string{
+	"title":           "title",
+	"subject":         "subject",
+	"description":     "description",
+	"creator":         "creator",
+	"initial-creator": "initial creator",
+	"lastmodifiedby":  "last modified by",
+	"keywords":        "keywords",
+	"keyword":         "keywords",
+	"language":        "language",
+	"created":         "created",
+	"creation-date":   "created",
+	"modified":        "modified",
+	"date":            "modified",
+	"application":     "application",
+	"generator":       "application",
+	"pages":           "pages",
+	"words":           "words",
+}
+
+type docxExtractor struct{}
+
+func (docxExtractor) container() {}
+
+func (docxExtractor) Extract(filePath string, contents []byte) (Document, error) {
+	archive, err := zip.NewReader(bytes.NewReader(contents), int64(len(contents)))
+	if err != nil {
+		return Document{}, err
+	}
+
+	return extractOffice(archive, "word/document.xml", docxRules, "docProps/core.xml", "docProps/app.xml")
+}
+
+type odtExtractor struct{}
+
+func (odtExtractor) container() {}
+
+func (odtExtractor) Extract(filePath string, contents []byte) (Document, error) {
+	archive, err := zip.NewReader(bytes.NewReader(contents), int64(len(contents)))
+	if err != nil {
+		return Document{}, err
+	}
+
+	return extractOffice(archive, "content.xml", odtRules, "meta.xml")
+}
+
+func extractOffice(archive *zip.Reader, body string, rules markupRules, metaFiles ...string) (Document, error) {
+	bodyXML, err := readMember(archive, body)
+	if err != nil {
+		return Document{}, err
+	}
+	document, err := rules.extract(bodyXML)
+	if err != nil {
+		return Document{}, err
+	}
+
+	for _, metaFile := range metaFiles {
+		metaXML, err := readMember(archive, metaFile)
+		if err != nil {
+			// metadata is optional, plenty of generators leave it out
+			continue
+		}
+		fields, err := xmlFields(metaXML, officeFields)
+		if err != nil {
+			continue
+		}
+		for key, value := range fields {
+			document.Metadata[key] = value
+		}
+	}
+	document.Title = document.Metadata["title"]
+
+	return document, nil
+}
+
+// readMember reads a member of a zip based document, up to maxContentSize. The size in the zip
+// header is checked first, and the read is capped as well since the header may not tell the truth.
+func readMember(archive *zip.Reader, name string) ([]byte, error) {
+	member, err := archive.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("missing %s: %w", name, err)
+	}
+	defer member.Close()
+
+	info, err := member.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", name, err)
+	}
+	if info.Size() > maxContentSize {
+		return nil, memberTooLarge(name)
+	}
+
+	contents, err := io.ReadAll(io.LimitReader(member, maxContentSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", name, err)
+	}
+	if len(contents) > maxContentSize {
+		return nil, memberTooLarge(name)
+	}
+
+	return contents, nil
+}
+
+func memberTooLarge(name string) error {
+	return fmt.Errorf("%s unpacks to more than %s: %w", name, utils.FormatSize(maxContentSize), utils.ErrTooLarge)
+}
+
+type epubContainer struct {
+	Rootfiles []struct {
+		FullPath string `xml:"full-path,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+type epubPackage struct {
+	Metadata struct {
+		Titles      []string `xml:"title"`
+		Creators    []string `xml:"creator"`
+		Languages   []string `xml:"language"`
+		Publishers  []string `xml:"publisher"`
+		Dates       []string `xml:"date"`
+		Subjects    []string `xml:"subject"`
+		Identifiers []string `xml:"identifier"`
+	} `xml:"metadata"`
+	Manifest []struct {
+		ID   string `xml:"id,attr"`
+		Href string `xml:"href,attr"`
+	} `xml:"manifest>item"`
+	Spine []struct {
+		IDRef string `xml:"idref,attr"`
+	} `xml:"spine>itemref"`
+}
+
+type epubExtractor struct{}
+
+func (epubExtractor) container() {}
+
+// Extract follows the container to the package document and reads the chapters in spine order.
+func (epubExtractor) Extract(filePath string, contents []byte) (Document, error) {
+	archive, err := zip.NewReader(bytes.NewReader(contents), int64(len(contents)))
+	if err != nil {
+		return Document{}, err
+	}
+
+	containerXML, err := readMember(archive, "META-INF/container.xml")
+	if err != nil {
+		return Document{}, err
+	}
+	var container epubContainer
+	err = xml.Unmarshal(containerXML, &container)
+	if err != nil {
+		return Document{}, fmt.Errorf("invalid epub container: %w", err)
+	}
+	if len(container.Rootfiles) == 0 {
+		return Document{}, fmt.Errorf("epub container lists no package document")
+	}
+
+	packagePath := container.Rootfiles[0].FullPath
+	packageXML, err := readMember(archive, packagePath)
+	if err != nil {
+		return Document{}, err
+	}
+	var pkg epubPackage
+	err = xml.Unmarshal(packageXML, &pkg)
+	if err != nil {
+		return Document{}, fmt.Errorf("invalid epub package: %w", err)
+	}
+
+	document := Document{Metadata: map[string]string{}}
+	for key, values := range map[string][]string{
+		"title":      pkg.Metadata.Titles,
+		"creator":    pkg.Metadata.Creators,
+		"language":   pkg.Metadata.Languages,
+		"publisher":  pkg.Metadata.Publishers,
+		"date":       pkg.Metadata.Dates,
+		"subject":    pkg.Metadata.Subjects,
+		"identifier": pkg.Metadata.Identifiers,
+	} {
+		if joined := joinFields(values); joined != "" {
+			document.Metadata[key] = joined
+		}
+	}
+	if len(pkg.Metadata.Titles) > 0 {
+		document.Title = strings.TrimSpace(pkg.Metadata.Titles[0])
+	}
+
+	hrefs := make(map[string]string, len(pkg.Manifest))
+	for _, item := range pkg.Manifest {
+		hrefs[item.ID] = item.Href
+	}
+
+	var text bytes.Buffer
+	baseDir := path.Dir(packagePath)
+	for _, itemRef := range pkg.Spine {
+		href, ok := hrefs[itemRef.IDRef]
+		if !ok {
+			continue
+		}
+		if unescaped, err := url.PathUnescape(href); err == nil {
+			href = unescaped
+		}
+		chapterXML, err := readMember(archive, path.Join(baseDir, href))
+		if err != nil {
+			continue
+		}
+		chapter, err := htmlExtractor{}.Extract(href, chapterXML)
+		if err != nil || len(chapter.Text) == 0 {
+			continue
+		}
+		if text.Len() > 0 {
+			text.WriteByte('\n')
+		}
+		text.Write(chapter.Text)
+	}
+	document.Text = text.Bytes()
+
+	return document, nil
+}
+
+func joinFields(values []string) string {
+	var cleaned []string
+	for _, value := range values {
+		value = strings.Join(strings.Fields(value), " ")
+		if value != "" {
+			cleaned = append(cleaned, value)
+		}
+	}
+
+	return strings.Join(cleaned, ", ")
+}
@@ -0,0 +1,161 @@
+package extract
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"icu/utils"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type jsonExtractor struct{}
+
+// Extract lists every scalar as "key: value" on its own line. Newline delimited JSON is handled
+// by decoding values until the input ends.
+func (jsonExtractor) Extract(path string, contents []byte) (Document, error) {
+	decoder := json.NewDecoder(bytes.NewReader(contents))
+	decoder.UseNumber()
+
+	document := Document{Metadata: map[string]string{}}
+	var text textBuilder
+	values := 0
+	for {
+		var value any
+		err := decoder.Decode(&value)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Document{}, fmt.Errorf("invalid json: %w", err)
+		}
+
+		if values == 0 {
+			describeJSON(value, &document)
+		}
+		writeJSON(&text, "", value)
+		values++
+	}
+	if values > 1 {
+		document.Metadata["records"] = strconv.Itoa(values)
+	}
+	document.Text = utils.NormalizeContent(text.Bytes())
+
+	return document, nil
+}
+
+func describeJSON(value any, document *Document) {
+	object, ok := value.(map[string]any)
+	if !ok {
+		if list, isList := value.([]any); isList {
+			document.Metadata["items"] = strconv.Itoa(len(list))
+		}
+		return
+	}
+
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	document.Metadata["keys"] = strings.Join(keys, ", ")
+
+	for _, key := range []string{"title", "name"} {
+		if title, isString := object[key].(string); isString && title != "" {
+			document.Title = title
+			return
+		}
+	}
+}
+
+func writeJSON(text *textBuilder, key string, value any) {
+	switch v := value.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for childKey := range v {
+			keys = append(keys, childKey)
+		}
+		sort.Strings(keys)
+		for _, childKey := range keys {
+			writeJSON(text, childKey, v[childKey])
+		}
+	case []any:
+		for _, item := range v {
+			writeJSON(text, key, item)
+		}
+	case nil:
+	default:
+		if key != "" {
+			text.WriteString(key)
+			text.WriteString(": ")
+		}
+		text.WriteString(strings.ReplaceAll(fmt.Sprint(v), "\n", " "))
+		text.WriteByte('\n')
+	}
+}
+
+type csvExtractor struct{}
+
+// Extract writes one record per line with the fields separated by tabs. The delimiter is guessed
+// from the first line, so semicolon and tab separated exports work as well.
+func (csvExtractor) Extract(path string, contents []byte) (Document, error) {
+	reader := csv.NewReader(bytes.NewReader(contents))
+	reader.Comma = guessDelimiter(contents)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	document := Document{Metadata: map[string]string{}}
+	var text textBuilder
+	rows := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) && rows > 0 {
+				break
+			}
+			return Document{}, fmt.Errorf("invalid csv: %w", err)
+		}
+
+		if rows == 0 {
+			document.Metadata["columns"] = strings.Join(record, ", ")
+		}
+		for i, field := range record {
+			if i > 0 {
+				text.WriteByte('\t')
+			}
+			text.WriteString(strings.ReplaceAll(field, "\n", " "))
+		}
+		text.WriteByte('\n')
+		rows++
+	}
+	document.Metadata["rows"] = strconv.Itoa(rows)
+	document.Text = utils.NormalizeContent(text.Bytes())
+
+	return document, nil
+}
+
+func guessDelimiter(contents []byte) rune {
+	firstLine := contents
+	if newline := bytes.IndexByte(contents, '\n'); newline >= 0 {
+		firstLine = contents[:newline]
+	}
+
+	delimiter := ','
+	most := bytes.Count(firstLine, []byte{','})
+	for _, candidate := range []rune{';', '\t', '|'} {
+		if count := bytes.Count(firstLine, []byte(string(candidate))); count > most {
+			delimiter = candidate
+			most = count
+		}
+	}
+
+	return delimiter
+}
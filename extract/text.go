@@ -0,0 +1,33 @@
+package extract
+
+import (
+	"bufio"
+	"bytes"
+	"icu/utils"
+	"strings"
+)
+
+type textExtractor struct{}
+
+func (textExtractor) Extract(path string, contents []byte) (Document, error) {
+	return Document{Text: utils.NormalizeContent(contents)}, nil
+}
+
+// markdownExtractor indexes the source as is and takes the first top level heading as title.
+type markdownExtractor struct{}
+
+func (markdownExtractor) Extract(path string, contents []byte) (Document, error) {
+	text := utils.NormalizeContent(contents)
+	document := Document{Text: text}
+
+	scanner := bufio.NewScanner(bytes.NewReader(text))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "# ") {
+			document.Title = strings.TrimSpace(strings.TrimPrefix(line, "# "))
+			break
+		}
+	}
+
+	return document, nil
+}
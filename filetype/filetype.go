@@ -0,0 +1,219 @@
+package filetype
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	sniffLength = 512
+	Directory   = "inode/directory"
+	Empty       = "inode/x-empty"
+)
+
+type signature struct {
+	offset   int
+	magic    []byte
+	mimeType string
+}
+
+// signatures covers formats http.DetectContentType does not know or only reports as
+// application/octet-stream. It is checked first.
+var signatures = []signature{
+	{0, []byte("\x7fELF"), "application/x-executable"},
+	{0, []byte("SQLite format 3\x00"), "application/vnd.sqlite3"},
+	{0, []byte("\x1f\x8b"), "application/gzip"},
+	{0, []byte("BZh"), "application/x-bzip2"},
+	{0, []byte("\xfd7zXZ\x00"), "application/x-xz"},
+	{0, []byte("\x28\xb5\x2f\xfd"), "application/zstd"},
+	{0, []byte("7z\xbc\xaf\x27\x1c"), "application/x-7z-compressed"},
+	{0, []byte("\xca\xfe\xba\xbe"), "application/java-vm"},
+	{0, []byte("\xcf\xfa\xed\xfe"), "application/x-mach-binary"},
+	{0, []byte("MZ"), "application/vnd.microsoft.portable-executable"},
+	{4, []byte("ftypheic"), "image/heic"},
+	{4, []byte("ftypqt"), "video/quicktime"},
+	{257, []byte("ustar"), "application/x-tar"},
+}
+
+var interpreters = map[string]string{
+	"sh":      "text/x-shellscript",
+	"bash":    "text/x-shellscript",
+	"zsh":     "text/x-shellscript",
+	"dash":    "text/x-shellscript",
+	"fish":    "text/x-shellscript",
+	"python":  "text/x-python",
+	"perl":    "text/x-perl",
+	"ruby":    "text/x-ruby",
+	"node":    "text/javascript",
+	"php":     "text/x-php",
+	"lua":     "text/x-lua",
+	"awk":     "text/x-awk",
+	"tclsh":   "text/x-tcl",
+	"Rscript": "text/x-r",
+}
+
+// extensionTypes refines text/plain and application/octet-stream for formats that are commonly
+// missing from the system mime tables.
+var extensionTypes = map[string]string{
+	".go":   "text/x-go",
+	".py":   "text/x-python",
+	".rs":   "text/x-rust",
+	".c":    "text/x-c",
+	".h":    "text/x-c",
+	".cpp":  "text/x-c++",
+	".hpp":  "text/x-c++",
+	".java": "text/x-java",
+	".kt":   "text/x-kotlin",
+	".sh":   "text/x-shellscript",
+	".md":   "text/markdown",
+	".toml": "application/toml",
+	".yaml": "application/yaml",
+	".yml":  "application/yaml",
+	".json": "application/json",
+	".csv":  "text/csv",
+	".sql":  "application/sql",
+	".ts":   "text/typescript",
+	".mod":  "text/plain",
+	".sum":  "text/plain",
+}
+
+var zipContainers = map[string]string{
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	".jar":  "application/java-archive",
+	".apk":  "application/vnd.android.package-archive",
+}
+
+func ReadHead(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+
+	return head[:n], nil
+}
+
+// Sniff reads the start of the file at path and detects its MIME type. When the file cannot be
+// read the type is guessed from the name alone.
+func Sniff(path string) (string, error) {
+	head, err := ReadHead(path)
+	if err != nil {
+		return Detect(filepath.Base(path), nil), err
+	}
+
+	return Detect(filepath.Base(path), head), nil
+}
+
+// Detect determines the MIME type of a file from its leading bytes, falling back to the
+// extension of name when the content is too generic to tell.
+func Detect(name string, head []byte) string {
+	extension := strings.ToLower(filepath.Ext(name))
+	if head == nil {
+		return byExtension(extension, "application/octet-stream")
+	}
+	if len(head) == 0 {
+		return Empty
+	}
+
+	for _, candidate := range signatures {
+		end := candidate.offset + len(candidate.magic)
+		if len(head) >= end && bytes.Equal(head[candidate.offset:end], candidate.magic) {
+			return candidate.mimeType
+		}
+	}
+
+	if bytes.HasPrefix(head, []byte("#!")) {
+		if mimeType, ok := fromShebang(head); ok {
+			return mimeType
+		}
+	}
+
+	detected := http.DetectContentType(head)
+	if mediaType, _, err := mime.ParseMediaType(detected); err == nil {
+		detected = mediaType
+	}
+
+	switch detected {
+	case "application/zip":
+		return zipType(head, extension)
+	case "text/plain", "application/octet-stream":
+		return byExtension(extension, detected)
+	}
+
+	return detected
+}
+
+func fromShebang(head []byte) (string, bool) {
+	line := head[2:]
+	if newline := bytes.IndexByte(line, '\n'); newline >= 0 {
+		line = line[:newline]
+	}
+
+	fields := strings.Fields(string(line))
+	if len(fields) == 0 {
+		return "", false
+	}
+	interpreter := filepath.Base(fields[0])
+	if interpreter == "env" {
+		for _, field := range fields[1:] {
+			if !strings.HasPrefix(field, "-") {
+				interpreter = field
+				break
+			}
+		}
+	}
+
+	interpreter = strings.TrimRight(interpreter, "0123456789.")
+	if mimeType, ok := interpreters[interpreter]; ok {
+		return mimeType, true
+	}
+
+	return "text/x-script", true
+}
+
+// zipType tells zip based document formats apart. ODF and EPUB store their MIME type uncompressed
+// as the first member, named "mimetype", which starts at offset 30 of the archive.
+func zipType(head []byte, extension string) string {
+	const mimetypeMember = "mimetype"
+	if len(head) > 30+len(mimetypeMember) && string(head[30:30+len(mimetypeMember)]) == mimetypeMember {
+		rest := head[30+len(mimetypeMember):]
+		end := bytes.IndexAny(rest, "PK\x00")
+		if end > 0 {
+			return string(rest[:end])
+		}
+	}
+
+	if mimeType, ok := zipContainers[extension]; ok {
+		return mimeType
+	}
+
+	return "application/zip"
+}
+
+func byExtension(extension string, fallback string) string {
+	if mimeType, ok := extensionTypes[extension]; ok {
+		return mimeType
+	}
+	if extension != "" {
+		if mimeType := mime.TypeByExtension(extension); mimeType != "" {
+			if mediaType, _, err := mime.ParseMediaType(mimeType); err == nil {
+				return mediaType
+			}
+		}
+	}
+
+	return fallback
+}
@@ -0,0 +1,67 @@
+package filetype
+
+import "io/fs"
+
+// Entry types as reported by lstat. Only regular files are ever opened for their content.
+const (
+	EntryFile        = "file"
+	EntryDirectory   = "dir"
+	EntrySymlink     = "symlink"
+	EntryFIFO        = "fifo"
+	EntrySocket      = "socket"
+	EntryBlockDevice = "block_device"
+	EntryCharDevice  = "char_device"
+	EntryIrregular   = "irregular"
+)
+
+const (
+	Symlink     = "inode/symlink"
+	FIFO        = "inode/fifo"
+	Socket      = "inode/socket"
+	BlockDevice = "inode/blockdevice"
+	CharDevice  = "inode/chardevice"
+)
+
+var kindTypes = map[string]string{
+	EntryDirectory:   Directory,
+	EntrySymlink:     Symlink,
+	EntryFIFO:        FIFO,
+	EntrySocket:      Socket,
+	EntryBlockDevice: BlockDevice,
+	EntryCharDevice:  CharDevice,
+}
+
+// Kind names the entry type of mode, which must come from lstat to tell symlinks apart.
+func Kind(mode fs.FileMode) string {
+	switch {
+	case mode.IsRegular():
+		return EntryFile
+	case mode.IsDir():
+		return EntryDirectory
+	case mode&fs.ModeSymlink != 0:
+		return EntrySymlink
+	case mode&fs.ModeNamedPipe != 0:
+		return EntryFIFO
+	case mode&fs.ModeSocket != 0:
+		return EntrySocket
+	case mode&fs.ModeCharDevice != 0:
+		return EntryCharDevice
+	case mode&fs.ModeDevice != 0:
+		return EntryBlockDevice
+	default:
+		return EntryIrregular
+	}
+}
+
+// KindType is the MIME type stored for entries that are not sniffed, in the inode/ namespace of
+// the shared MIME database. Regular files have none.
+func KindType(kind string) string {
+	if mimeType, ok := kindTypes[kind]; ok {
+		return mimeType
+	}
+	if kind == EntryIrregular {
+		return "inode/x-irregular"
+	}
+
+	return ""
+}
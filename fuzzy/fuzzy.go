@@ -0,0 +1,131 @@
+package fuzzy
+
+import (
+	"database/sql"
+	"fmt"
+	"icu/data"
+	"icu/db"
+	"icu/utils"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const resultLimit = 50
+
+type scored struct {
+	result data.SearchResult
+	score  int
+}
+
+func matchAll(fragments []string, path string) (int, bool) {
+	total := 0
+	for _, fragment := range fragments {
+		score, ok := Score(fragment, path)
+		if !ok {
+			return 0, false
+		}
+		total += score
+	}
+
+	return total, true
+}
+
+// Rank scores every candidate path against the space separated fragments of pattern, all of which
+// have to match, and returns the best limit results with Rank set to the negated score.
+func Rank(pattern string, candidates []data.SearchResult, limit int) []data.SearchResult {
+	fragments := strings.Fields(pattern)
+	if len(fragments) == 0 {
+		return nil
+	}
+
+	workers := runtime.NumCPU()
+	chunkSize := (len(candidates) + workers - 1) / workers
+	partials := make([][]scored, workers)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		start := w * chunkSize
+		if start >= len(candidates) {
+			break
+		}
+		end := min(start+chunkSize, len(candidates))
+
+		wg.Add(1)
+		go func(w int, chunk []data.SearchResult) {
+			defer wg.Done()
+			for _, candidate := range chunk {
+				if score, ok := matchAll(fragments, candidate.Path); ok {
+					partials[w] = append(partials[w], scored{result: candidate, score: score})
+				}
+			}
+		}(w, candidates[start:end])
+	}
+	wg.Wait()
+
+	var matches []scored
+	for _, partial := range partials {
+		matches = append(matches, partial...)
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		if len(matches[i].result.Path) != len(matches[j].result.Path) {
+			return len(matches[i].result.Path) < len(matches[j].result.Path)
+		}
+		return matches[i].result.Path < matches[j].result.Path
+	})
+
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+
+	results := make([]data.SearchResult, len(matches))
+	for i, match := range matches {
+		results[i] = match.result
+		results[i].Rank = -float64(match.score)
+	}
+
+	return results
+}
+
+func Main(terms []string) error {
+	pattern := strings.Join(terms, " ")
+	if strings.TrimSpace(pattern) == "" {
+		return fmt.Errorf("usage: fuzzy <pattern>")
+	}
+
+	dbPath, err := db.GetDBPath()
+	if err != nil {
+		return err
+	}
+	con, err := db.CreateConnection(dbPath)
+	if err != nil {
+		return err
+	}
+	defer func(con *sql.DB) {
+		err = db.CloseConnection(con)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}(con)
+
+	candidates, err := data.GetSearchCandidates(con)
+	if err != nil {
+		return err
+	}
+
+	results := Rank(pattern, candidates, resultLimit)
+	if len(results) == 0 {
+		fmt.Println("no matches")
+		return nil
+	}
+	for _, result := range results {
+		fmt.Printf("%s\t%s\t%s\n", result.Path, utils.FormatSize(result.Size), result.ModificationTime.Format("2006-01-02 15:04"))
+	}
+
+	return nil
+}
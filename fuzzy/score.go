@@ -0,0 +1,146 @@
+package fuzzy
+
+import (
+	"unicode"
+)
+
+const (
+	scoreMatch        = 16
+	scoreGapStart     = -3
+	scoreGapExtension = -1
+
+	bonusSegment          = scoreMatch / 2
+	bonusBoundary         = scoreMatch/2 - 1
+	bonusCamelCase        = scoreMatch/2 - 2
+	bonusConsecutive      = 4
+	firstCharMultiplier   = 2
+	pathLengthBonusLength = 64
+)
+
+const noMatch = -1 << 30
+
+// Score aligns pattern against text the way fzf does: every pattern rune has to appear in order,
+// runs of consecutive runes and runes at path segments, word boundaries and camelCase humps earn
+// bonuses, and gaps between matched runes cost a little. Shorter texts get a small bonus on top
+// so that ties favour shallow paths.
+func Score(pattern, text string) (int, bool) {
+	patternRunes := []rune(pattern)
+	textRunes := []rune(text)
+	if len(patternRunes) == 0 {
+		return 0, true
+	}
+	if len(patternRunes) > len(textRunes) {
+		return 0, false
+	}
+
+	caseSensitive := hasUpper(patternRunes)
+	if !isSubsequence(patternRunes, textRunes, caseSensitive) {
+		return 0, false
+	}
+
+	bonuses := make([]int, len(textRunes))
+	for j := range textRunes {
+		bonuses[j] = positionBonus(textRunes, j)
+	}
+
+	previous := make([]int, len(textRunes))
+	current := make([]int, len(textRunes))
+	for i, patternRune := range patternRunes {
+		gap := noMatch
+		for j, textRune := range textRunes {
+			if i > 0 && j >= 2 {
+				gap = max(gap+scoreGapExtension, previous[j-2]+scoreGapStart)
+			}
+
+			current[j] = noMatch
+			if !runesEqual(patternRune, textRune, caseSensitive) {
+				continue
+			}
+
+			if i == 0 {
+				current[j] = scoreMatch + bonuses[j]*firstCharMultiplier
+				continue
+			}
+			if j == 0 {
+				continue
+			}
+
+			best := gap
+			if previous[j-1] > noMatch {
+				best = max(best, previous[j-1]+bonusConsecutive)
+			}
+			if best <= noMatch/2 {
+				continue
+			}
+			current[j] = best + scoreMatch + bonuses[j]
+		}
+		previous, current = current, previous
+	}
+
+	best := noMatch
+	for _, score := range previous {
+		best = max(best, score)
+	}
+	if best <= noMatch/2 {
+		return 0, false
+	}
+
+	lengthBonus := pathLengthBonusLength - len(textRunes)/4
+	if lengthBonus < 0 {
+		lengthBonus = 0
+	}
+
+	return best + lengthBonus, true
+}
+
+func positionBonus(text []rune, j int) int {
+	if j == 0 {
+		return bonusSegment
+	}
+
+	previous, current := text[j-1], text[j]
+	switch {
+	case previous == '/':
+		return bonusSegment
+	case previous == '_' || previous == '-' || previous == '.' || previous == ' ':
+		return bonusBoundary
+	case unicode.IsLower(previous) && unicode.IsUpper(current):
+		return bonusCamelCase
+	case !unicode.IsDigit(previous) && unicode.IsDigit(current):
+		return bonusCamelCase
+	}
+
+	return 0
+}
+
+func isSubsequence(pattern, text []rune, caseSensitive bool) bool {
+	i := 0
+	for _, textRune := range text {
+		if runesEqual(pattern[i], textRune, caseSensitive) {
+			i++
+			if i == len(pattern) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func runesEqual(patternRune, textRune rune, caseSensitive bool) bool {
+	if caseSensitive {
+		return patternRune == textRune
+	}
+
+	return patternRune == unicode.ToLower(textRune)
+}
+
+func hasUpper(runes []rune) bool {
+	for _, r := range runes {
+		if unicode.IsUpper(r) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,88 @@
+package fuzzy
+
+import (
+	"icu/data"
+	"testing"
+)
+
+func TestScoreOrdering(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		better  string
+		worse   string
+	}{
+		{"contiguous run", "scan", "src/scanner.go", "src/sxcxaxn.go"},
+		{"contiguous run over scattered", "index", "pkg/index.go", "pkg/inxdex.go"},
+		{"segment start", "conf", "app/config.go", "app/xconfig.go"},
+		{"segment over word boundary", "util", "src/utils.go", "src/my_utils.go"},
+		{"word boundary", "walk", "src/file_walker.go", "src/filewalker.go"},
+		{"camelCase hump", "fb", "src/fooBar.go", "src/foobar.go"},
+		{"digit hump", "v2", "api/apiV2.go", "api/apiv02.go"},
+		{"shorter path", "main", "cmd/main.go", "very/deeply/nested/project/directory/structure/for/testing/cmd/main.go"},
+		{"abbreviation", "mntorch", "maintain/orchestration.go", "mainland/contortionist/chapters.go"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			better, ok := Score(test.pattern, test.better)
+			if !ok {
+				t.Fatalf("%q does not match %q", test.pattern, test.better)
+			}
+			worse, ok := Score(test.pattern, test.worse)
+			if !ok {
+				t.Fatalf("%q does not match %q", test.pattern, test.worse)
+			}
+			if better <= worse {
+				t.Errorf("%q scores %d on %q, want more than %d on %q", test.pattern, better, test.better, worse, test.worse)
+			}
+		})
+	}
+}
+
+func TestScoreMatching(t *testing.T) {
+	tests := []struct {
+		pattern string
+		text    string
+		matches bool
+	}{
+		{"", "anything", true},
+		{"mntorch", "maintain/orchestration.go", true},
+		{"abc", "a/b/c", true},
+		{"cba", "a/b/c", false},
+		{"toolong", "short", false},
+		// lower case patterns ignore case, a capital makes the pattern case sensitive
+		{"readme", "README.md", true},
+		{"README", "readme.md", false},
+		{"ReadMe", "ReadMe.md", true},
+	}
+
+	for _, test := range tests {
+		if _, matches := Score(test.pattern, test.text); matches != test.matches {
+			t.Errorf("Score(%q, %q) matches %v, want %v", test.pattern, test.text, matches, test.matches)
+		}
+	}
+}
+
+func TestRankAbbreviation(t *testing.T) {
+	var candidates []data.SearchResult
+	for _, path := range []string{
+		"maintain/maintain.go",
+		"maintain/orchestration.go",
+		"maintain/reading.go",
+		"mainland/contortionist/chapters.go",
+		"monitor/orchestra/conductor/harmonics.go",
+	} {
+		candidates = append(candidates, data.SearchResult{Path: path})
+	}
+
+	results := Rank("mntorch", candidates, 10)
+	if len(results) == 0 || results[0].Path != "maintain/orchestration.go" {
+		t.Fatalf("got %v, want maintain/orchestration.go first", results)
+	}
+	for i := 1; i < len(results); i++ {
+		if results[i].Rank < results[i-1].Rank {
+			t.Errorf("%s ranked %v after %s ranked %v", results[i].Path, results[i].Rank, results[i-1].Path, results[i-1].Rank)
+		}
+	}
+}
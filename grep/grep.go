@@ -0,0 +1,238 @@
+package grep
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"flag"
+	"fmt"
+	"icu/data"
+	"icu/db"
+	"icu/utils"
+	"os"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+const jobBufferSize = 64
+
+var highlightStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("9"))
+
+type grepJob struct {
+	seq      int
+	path     string
+	fullText []byte
+}
+
+type grepResult struct {
+	seq   int
+	lines []string
+}
+
+type matcher struct {
+	pattern *regexp.Regexp
+}
+
+func newMatcher(pattern string, literal, ignoreCase bool) (matcher, error) {
+	if literal {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return matcher{}, fmt.Errorf("invalid pattern: %w", err)
+	}
+
+	return matcher{pattern: compiled}, nil
+}
+
+// prefilter returns a literal that every match has to contain so sqlite can drop documents
+// before they are handed to the regexp engine.
+func prefilter(pattern string, literal, ignoreCase bool) string {
+	if ignoreCase {
+		return ""
+	}
+	if literal {
+		return pattern
+	}
+
+	prefix, _ := regexp.MustCompile(pattern).LiteralPrefix()
+	return prefix
+}
+
+func (m matcher) grepDocument(path string, fullText []byte) []string {
+	if !m.pattern.Match(fullText) {
+		return nil
+	}
+
+	var lines []string
+	lineNumber := 0
+	for len(fullText) > 0 {
+		lineNumber++
+		line := fullText
+		if newline := bytes.IndexByte(fullText, '\n'); newline >= 0 {
+			line = fullText[:newline]
+			fullText = fullText[newline+1:]
+		} else {
+			fullText = nil
+		}
+
+		locations := m.pattern.FindAllIndex(line, -1)
+		if len(locations) == 0 {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s:%d:%s", path, lineNumber, highlight(line, locations)))
+	}
+
+	return lines
+}
+
+func highlight(line []byte, locations [][]int) string {
+	var highlighted strings.Builder
+	position := 0
+	for _, location := range locations {
+		if location[0] == location[1] || !utf8.Valid(line[location[0]:location[1]]) {
+			continue
+		}
+		highlighted.Write(line[position:location[0]])
+		highlighted.WriteString(highlightStyle.Render(string(line[location[0]:location[1]])))
+		position = location[1]
+	}
+	highlighted.Write(line[position:])
+
+	return highlighted.String()
+}
+
+func grepWorker(jobs <-chan grepJob, results chan<- grepResult, m matcher, wg *sync.WaitGroup) {
+	defer wg.Done()
+	for job := range jobs {
+		results <- grepResult{seq: job.seq, lines: m.grepDocument(job.path, job.fullText)}
+	}
+}
+
+func Main(arguments []string) error {
+	flags := flag.NewFlagSet("grep", flag.ContinueOnError)
+	flags.SetOutput(os.Stdout)
+	literal := flags.Bool("F", false, "treat the pattern as a literal string")
+	ignoreCase := flags.Bool("i", false, "ignore case")
+	pathPrefix := flags.String("path", "", "only search entries below this path")
+	extensions := flags.String("ext", "", "comma separated extensions to search, e.g. .go,.md")
+	err := flags.Parse(arguments)
+	if err != nil {
+		return err
+	}
+
+	pattern := strings.Join(flags.Args(), " ")
+	if pattern == "" {
+		return fmt.Errorf("usage: grep [-F] [-i] [-path dir] [-ext .go,.md] <pattern>")
+	}
+
+	m, err := newMatcher(pattern, *literal, *ignoreCase)
+	if err != nil {
+		return err
+	}
+
+	filter := data.ContentFilter{Contains: prefilter(pattern, *literal, *ignoreCase)}
+	if *pathPrefix != "" {
+		filter.PathPrefix, err = utils.ExpandPath(*pathPrefix)
+		if err != nil {
+			return err
+		}
+	}
+	for _, extension := range strings.Split(*extensions, ",") {
+		extension = strings.TrimSpace(extension)
+		if extension == "" {
+			continue
+		}
+		if !strings.HasPrefix(extension, ".") {
+			extension = "." + extension
+		}
+		filter.Extensions = append(filter.Extensions, extension)
+	}
+
+	dbPath, err := db.GetDBPath()
+	if err != nil {
+		return err
+	}
+	con, err := db.CreateConnection(dbPath)
+	if err != nil {
+		return err
+	}
+	defer func(con *sql.DB) {
+		err = db.CloseConnection(con)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}(con)
+
+	jobs := make(chan grepJob, jobBufferSize)
+	results := make(chan grepResult, jobBufferSize)
+
+	var wg sync.WaitGroup
+	workers := runtime.NumCPU()
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go grepWorker(jobs, results, m, &wg)
+	}
+
+	printed := make(chan int)
+	go func() {
+		printed <- printInOrder(results)
+	}()
+
+	seq := 0
+	err = data.ForEachContent(con, filter, func(path string, fullText []byte) error {
+		jobs <- grepJob{seq: seq, path: path, fullText: fullText}
+		seq++
+		return nil
+	})
+	close(jobs)
+	wg.Wait()
+	close(results)
+	matchCount := <-printed
+
+	if err != nil {
+		return err
+	}
+	if matchCount == 0 {
+		fmt.Println("no matches")
+	}
+
+	return nil
+}
+
+// printInOrder writes results in the order the documents were read, so output is sorted by path
+// regardless of which worker finished first.
+func printInOrder(results <-chan grepResult) int {
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
+	pending := make(map[int][]string)
+	next := 0
+	matchCount := 0
+	for result := range results {
+		pending[result.seq] = result.lines
+		for {
+			lines, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			for _, line := range lines {
+				matchCount++
+				fmt.Fprintln(out, line)
+			}
+		}
+	}
+
+	return matchCount
+}
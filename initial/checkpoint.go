@@ -0,0 +1,163 @@
+package initial
+
+import (
+	"database/sql"
+	"icu/data"
+	"icu/db"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// progress finds the directories whose whole subtree is written. The traversal counts every job it
+// dispatches against the directory it belongs to and all of that directory's ancestors, the writer
+// counts them down once their batch is committed. A directory is complete when its count is back
+// at zero after the traversal has left its subtree.
+type progress struct {
+	mu          sync.Mutex
+	root        string
+	outstanding map[string]int
+	closed      map[string]bool
+	completed   []string
+}
+
+func newProgress(root string) *progress {
+	return &progress{
+		root:        root,
+		outstanding: make(map[string]int),
+		closed:      make(map[string]bool),
+	}
+}
+
+func (p *progress) ancestors(directory string, visit func(string)) {
+	for {
+		visit(directory)
+		if directory == p.root || !strings.HasPrefix(directory, p.root) {
+			return
+		}
+		parent := filepath.Dir(directory)
+		if parent == directory {
+			return
+		}
+		directory = parent
+	}
+}
+
+func (p *progress) dispatched(directory string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.ancestors(directory, func(ancestor string) {
+		p.outstanding[ancestor]++
+	})
+}
+
+// close is called by the traversal once every job below directory is dispatched.
+func (p *progress) close(directory string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed[directory] = true
+	if p.outstanding[directory] == 0 {
+		p.complete(directory)
+	}
+}
+
+// written is called by the writer with the directories of a committed batch.
+func (p *progress) written(directories []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, directory := range directories {
+		p.ancestors(directory, func(ancestor string) {
+			p.outstanding[ancestor]--
+			if p.outstanding[ancestor] == 0 && p.closed[ancestor] {
+				p.complete(ancestor)
+			}
+		})
+	}
+}
+
+func (p *progress) complete(directory string) {
+	delete(p.outstanding, directory)
+	delete(p.closed, directory)
+	p.completed = append(p.completed, directory)
+}
+
+// drain hands over the directories completed since the last call.
+func (p *progress) drain() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	completed := p.completed
+	p.completed = nil
+
+	return completed
+}
+
+// isFinished reports whether path lies in a subtree that a checkpoint marks as written.
+func isFinished(path string, finished map[string]bool) bool {
+	for {
+		if finished[path] {
+			return true
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return false
+		}
+		path = parent
+	}
+}
+
+// pruneUnfinished prepares the next generation for a resumed scan. Everything outside the finished
+// subtrees is deleted, since the scan reads it again, and the counters start from what is kept.
+func pruneUnfinished(con *sql.DB, finished map[string]bool, theWorks *data.CollectedInfo, batchSize int) error {
+	tables := data.GenerationTables(db.NextGeneration)
+
+	var unfinished []int64
+	err := data.ForEachIndexedEntry(con, tables, func(entry data.IndexedEntry) error {
+		if !isFinished(entry.Path, finished) {
+			unfinished = append(unfinished, entry.EntryID)
+			return nil
+		}
+		if entry.IsDir {
+			theWorks.NumOfDirectories += 1
+		} else {
+			theWorks.NumOfFiles += 1
+		}
+		if entry.HasContent {
+			theWorks.NumOfFilesWithContent += 1
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	ignored, err := data.GetNotRegisteredEntries(con, tables)
+	if err != nil {
+		return err
+	}
+
+	writer := data.NewBatchWriter(con, tables, batchSize, false)
+	defer writer.Rollback()
+	for _, entryID := range unfinished {
+		err = writer.DeleteEntry(entryID)
+		if err != nil {
+			return err
+		}
+	}
+	for _, entry := range ignored {
+		if isFinished(entry.Path, finished) {
+			theWorks.NumOfIgnoredEntries += 1
+			theWorks.IgnoredByClass[entry.Class] += 1
+			continue
+		}
+		err = writer.DeleteNotRegisteredEntry(entry.Path)
+		if err != nil {
+			return err
+		}
+	}
+
+	return writer.Commit()
+}
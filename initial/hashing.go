@@ -0,0 +1,18 @@
+package initial
+
+import (
+	"database/sql"
+	"icu/data"
+	"icu/dupes"
+)
+
+// hashSizeCollisions hashes the written files that share their size with another file. Files of a
+// unique size cannot have a duplicate, so reading them would be wasted effort.
+func hashSizeCollisions(con *sql.DB, batchSize int) error {
+	candidates, err := data.GetDuplicateCandidates(con, 1, "")
+	if err != nil {
+		return err
+	}
+
+	return dupes.FillMissingHashes(con, candidates, batchSize)
+}
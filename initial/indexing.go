@@ -3,51 +3,85 @@ package initial
 import (
 	"database/sql"
 	"fmt"
-	"log"
-	"os"
-	"path/filepath"
+	"time"
+	"icu/config"
 	"icu/data"
 	"icu/db"
 )
 
-func updateFullIndex(theWorks *data.CollectedInfo) error {
-	homePath, err := os.UserHomeDir()
+// openFullIndex logs the start of a scan and prepares empty tables for the next generation of the
+// index. The live index stays untouched until the scan completes.
+func openFullIndex(con *sql.DB, start time.Time) (int64, error) {
+	err := db.PrepareNextGeneration(con)
 	if err != nil {
-		log.Fatal(err)
+		return 0, err
 	}
-	dbPath := filepath.Join(homePath, ".icu", "icu.db")
-	con, err := db.CreateConnection(dbPath)
+
+	scanID, err := data.StartScanRecord(con, start)
 	if err != nil {
-		log.Fatal(err)
+		return 0, err
 	}
-	defer func(con *sql.DB) {
-		err = db.CloseConnection(con)
-		if err != nil {
-			fmt.Println(err)
-		}
-	}(con)
 
-	err = data.ClearExistingData(con)
+	err = data.DeleteScanCheckpoints(con, scanID)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	err = data.WriteFullEntries(con, theWorks.EntryDetails)
+	return scanID, nil
+}
+
+// resumeFullIndex picks up the last interrupted scan. It fills finished with the subtrees the
+// checkpoints vouch for and returns the id and start of the resumed scan.
+func resumeFullIndex(con *sql.DB, finished map[string]bool, theWorks *data.CollectedInfo, batchSize int) (int64, time.Time, error) {
+	scanID, start, err := data.GetResumableScan(con)
 	if err != nil {
-		return err
+		return 0, start, err
+	}
+	exists, err := db.HasNextGeneration(con)
+	if err != nil {
+		return 0, start, err
+	}
+	if !exists {
+		return 0, start, fmt.Errorf("the tables of scan %d are gone, start a new full scan", scanID)
+	}
+
+	directories, err := data.GetCheckpoints(con, scanID)
+	if err != nil {
+		return 0, start, err
+	}
+	for _, directory := range directories {
+		finished[directory] = true
+	}
+
+	err = pruneUnfinished(con, finished, theWorks, batchSize)
+	if err != nil {
+		return 0, start, err
 	}
+	fmt.Printf("Resuming scan %d, %d directories are already complete\n", scanID, len(directories))
 
-	err = data.WriteNotRegisteredEntries(con, theWorks.NotRegistered)
+	return scanID, start, nil
+}
+
+// completeFullIndex runs once every entry is written. It swaps the new generation in, hashes the
+// files sharing a size and only then marks the scan as completed. Hashing can fail without harm,
+// dupes fills in the missing hashes later.
+func completeFullIndex(con *sql.DB, scanID int64, theWorks *data.CollectedInfo, writing config.Writing) error {
+	err := db.SwapGeneration(con)
 	if err != nil {
 		return err
 	}
 
+	err = hashSizeCollisions(con, writing.BatchSize)
+	if err != nil {
+		fmt.Println(err)
+	}
+
 	theWorks.IndexingCompleted = true
 
-	err = data.WriteScanRecord(con, theWorks)
+	err = data.CompleteScanRecord(con, scanID, theWorks)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return data.DeleteScanCheckpoints(con, 0)
 }
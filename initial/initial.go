@@ -1,10 +1,14 @@
 package initial
 
 import (
+	"database/sql"
+	"flag"
 	"fmt"
-	"log"
 	"os"
+	"icu/config"
 	"icu/data"
+	"icu/db"
+	"icu/utils"
 	"sync"
 	"time"
 )
@@ -14,44 +18,115 @@ const (
 	fileWorkers            = 80
 	directoryJobBufferSize = 100
 	fileJobBufferSize      = 500
+	writeJobBufferSize     = 500
 )
 
-func StartInitialScan() {
-	start := time.Now()
-	theWorks := data.CollectedInfo{}
+func Main(arguments []string) error {
+	flags := flag.NewFlagSet("fullscan", flag.ContinueOnError)
+	flags.SetOutput(os.Stdout)
+	resume := flags.Bool("resume", false, "continue the last interrupted full scan from its checkpoints")
+	err := flags.Parse(arguments)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return fmt.Errorf("usage: fullscan [--resume]")
+	}
 
-	fileReadJobs := make(chan string, fileJobBufferSize)
-	dirReadJobs := make(chan string, directoryJobBufferSize)
+	return StartInitialScan(*resume)
+}
 
-	var wg sync.WaitGroup
-	totalWorkers := 1 + directoryWorkers + fileWorkers
-	wg.Add(totalWorkers)
+func StartInitialScan(resume bool) error {
+	start := time.Now()
+	theWorks := data.CollectedInfo{IgnoredByClass: make(map[string]int)}
 
 	path := "/home/utled/GolandProjects"
 	stat, err := os.Stat(path)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	if !stat.IsDir() {
-		log.Fatal("Starting path must be a directory")
+		return fmt.Errorf("starting path must be a directory")
+	}
+
+	loadedConfig, err := config.Load()
+	if err != nil {
+		return err
+	}
+	dbPath, err := db.GetDBPath()
+	if err != nil {
+		return err
+	}
+	con, err := db.CreateConnection(dbPath)
+	if err != nil {
+		return err
 	}
-	readDir(path, &theWorks, true)
+	defer func(con *sql.DB) {
+		err = db.CloseConnection(con)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}(con)
 
-	for i := 0; i < directoryWorkers; i += 1 {
-		go dirWorker(dirReadJobs, &wg, &theWorks)
+	var scanID int64
+	finished := make(map[string]bool)
+	if resume {
+		scanID, start, err = resumeFullIndex(con, finished, &theWorks, loadedConfig.Writing.BatchSize)
+	} else {
+		scanID, err = openFullIndex(con, start)
+	}
+	if err != nil {
+		return err
 	}
 
-	for i := 0; i < fileWorkers; i += 1 {
-		go fileWorker(fileReadJobs, &wg, &theWorks)
+	fileReadJobs := make(chan string, fileJobBufferSize)
+	dirReadJobs := make(chan string, directoryJobBufferSize)
+	writeJobs := make(chan writeJob, writeJobBufferSize)
+	tracker := newProgress(path)
+	policy := utils.LinkPolicy{
+		Root:          path,
+		Follow:        loadedConfig.Scanning.FollowSymlinks,
+		OneFilesystem: loadedConfig.Scanning.Root(path).OneFilesystem,
 	}
 
-	go traverseDirectory(path, dirReadJobs, fileReadJobs, &wg, &theWorks)
+	var wg sync.WaitGroup
+	var writerWG sync.WaitGroup
+
+	writer := data.NewBatchWriter(con, data.GenerationTables(db.NextGeneration), loadedConfig.Writing.BatchSize, loadedConfig.Writing.LogEntries)
+	writerWG.Add(1)
+	var failed firstError
+	go writeWorker(writeJobs, writer, tracker, scanID, &failed, &theWorks, &writerWG)
+
+	if !finished[path] {
+		totalWorkers := 1 + directoryWorkers + fileWorkers
+		wg.Add(totalWorkers)
 
-	wg.Wait()
+		tracker.dispatched(path)
+		readDir(path, policy, writeJobs, &theWorks, true)
+
+		for i := 0; i < directoryWorkers; i += 1 {
+			go dirWorker(dirReadJobs, policy, writeJobs, &wg, &theWorks)
+		}
+
+		for i := 0; i < fileWorkers; i += 1 {
+			go fileWorker(fileReadJobs, writeJobs, &wg, &theWorks)
+		}
+
+		go traverseDirectory(path, policy, dirReadJobs, fileReadJobs, writeJobs, tracker, finished, &failed, &wg, &theWorks)
+
+		wg.Wait()
+	}
+	close(writeJobs)
+	writerWG.Wait()
 	end := time.Now()
 	elapsed := end.Sub(start)
 
 	fmt.Printf("Full scan took %s\n", elapsed)
+	fmt.Println(utils.FailureSummary(theWorks.IgnoredByClass))
+	// the live index stays in place, the written part of the new one is kept for a resume
+	if err = failed.get(); err != nil {
+		return fmt.Errorf("full scan stopped, the live index is unchanged, run fullscan --resume to continue: %w", err)
+	}
 
 	theWorks.Mu.Lock()
 	theWorks.ScanStart = start
@@ -59,11 +134,13 @@ func StartInitialScan() {
 	theWorks.ScanDuration = elapsed
 	theWorks.Mu.Unlock()
 
-	writeStart := time.Now()
-	err = updateFullIndex(&theWorks)
+	completeStart := time.Now()
+	err = completeFullIndex(con, scanID, &theWorks, loadedConfig.Writing)
 	if err != nil {
-		fmt.Println(err)
+		return err
 	}
-	writeElapsed := time.Since(writeStart)
-	fmt.Printf("Full dbWrite took %s\n", writeElapsed)
+	completeElapsed := time.Since(completeStart)
+	fmt.Printf("Swapping in the new index took %s\n", completeElapsed)
+
+	return nil
 }
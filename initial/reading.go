@@ -1,24 +1,27 @@
 package initial
 
 import (
-	"bytes"
-	"log"
-	"os"
 	"path/filepath"
-	"regexp"
-	"slices"
+	"icu/archives"
 	"icu/data"
+	"icu/extract"
+	"icu/filetype"
+	"icu/similar"
+	"icu/symbols"
 	"icu/utils"
 	"syscall"
 	"time"
 )
 
-func readDir(path string, theWorks *data.CollectedInfo, isRoot bool) {
+// readDir reads a directory, or a symlink the traversal followed, which is stored with the
+// metadata of the directory it leads to.
+func readDir(path string, policy utils.LinkPolicy, writeJobs chan<- writeJob, theWorks *data.CollectedInfo, isRoot bool) {
 	entry := data.EntryCollection{}
 
-	dirStat, err := os.Stat(path)
+	dirStat, err := policy.StatEntry(path, &entry)
 	if err != nil {
-		log.Fatal(err)
+		recordFailure(utils.NewFailure(path, err), path, writeJobs, theWorks)
+		return
 	}
 
 	entry.FullPath = path
@@ -30,7 +33,9 @@ func readDir(path string, theWorks *data.CollectedInfo, isRoot bool) {
 	entry.Size = dirStat.Size()
 
 	statT := dirStat.Sys().(*syscall.Stat_t)
+	entry.Device = uint64(statT.Dev)
 	entry.Inode = statT.Ino
+	entry.Nlink = uint64(statT.Nlink)
 	entry.ModificationTime = time.Unix(statT.Mtim.Sec, statT.Mtim.Nsec)
 	entry.AccessTime = time.Unix(statT.Atim.Sec, statT.Atim.Nsec)
 	entry.MetaDataChangeTime = time.Unix(statT.Ctim.Sec, statT.Ctim.Nsec)
@@ -38,60 +43,36 @@ func readDir(path string, theWorks *data.CollectedInfo, isRoot bool) {
 	entry.OwnerID = statT.Uid
 	entry.GroupID = statT.Gid
 	entry.Extension = filepath.Ext(entry.Name)
-	entry.FileType = filepath.Ext(entry.Name)
+	entry.FileType = filetype.Directory
 
 	theWorks.Mu.Lock()
 	theWorks.NumOfDirectories += 1
-	theWorks.EntryDetails = append(theWorks.EntryDetails, &entry)
 	theWorks.Mu.Unlock()
+
+	writeJobs <- writeJob{entry: &entry, directory: path}
 }
 
-func readFile(filename string, theWorks *data.CollectedInfo) {
+// readFile reads everything the traversal does not descend into. Symlinks are stored as links and
+// special files by their metadata, only regular files are opened.
+func readFile(filename string, writeJobs chan<- writeJob, theWorks *data.CollectedInfo) {
 	entry := data.EntryCollection{}
 
-	contentsRead := false
-
-	if slices.Contains(utils.ContentFiles, filepath.Ext(filename)) {
-		contents, err := os.ReadFile(filename)
-		if err != nil {
-			log.Fatal(err)
-		}
-		contentsRead = true
-		lineCountTotal := bytes.Count(contents, []byte("\n"))
-		blankLines := bytes.Count(contents, []byte("\n\n"))
-		lineCountWithContent := lineCountTotal - blankLines
-
-		if len(contents) < 500 {
-			entry.ContentSnippet = contents
-		} else {
-			entry.ContentSnippet = contents[:500]
-		}
-
-		contents = bytes.ReplaceAll(contents, []byte("\n"), []byte(" "))
-		contents = bytes.ReplaceAll(contents, []byte("\r"), []byte(" "))
-		contents = bytes.ReplaceAll(contents, []byte("\t"), []byte(" "))
-
-		regExCleanup := regexp.MustCompile(`[\p{C}\p{Zl}\p{Zp}]`)
-		contents = regExCleanup.ReplaceAll(contents, []byte(" "))
-		contents = regexp.MustCompile(`\s+`).ReplaceAll(contents, []byte(" "))
-
-		entry.FullTextIndex = contents
-		entry.LineCountTotal = lineCountTotal
-		entry.LineCountWithContent = lineCountWithContent
-	}
-
-	fileStat, err := os.Stat(filename)
+	fileStat, err := utils.LinkPolicy{}.StatEntry(filename, &entry)
 	if err != nil {
-		log.Fatal(err)
+		recordFailure(utils.NewFailure(filename, err), filepath.Dir(filename), writeJobs, theWorks)
+		return
 	}
 	entry.FullPath = filename
 	entry.ParentDirID = filepath.Dir(filename)
 	entry.Name = filepath.Base(filename)
+	entry.Extension = filepath.Ext(entry.Name)
 	entry.IsDir = false
 	entry.Size = fileStat.Size()
 
 	statT := fileStat.Sys().(*syscall.Stat_t)
+	entry.Device = uint64(statT.Dev)
 	entry.Inode = statT.Ino
+	entry.Nlink = uint64(statT.Nlink)
 	entry.ModificationTime = time.Unix(statT.Mtim.Sec, statT.Mtim.Nsec)
 	entry.AccessTime = time.Unix(statT.Atim.Sec, statT.Atim.Nsec)
 	entry.MetaDataChangeTime = time.Unix(statT.Ctim.Sec, statT.Ctim.Nsec)
@@ -99,11 +80,75 @@ func readFile(filename string, theWorks *data.CollectedInfo) {
 	entry.OwnerID = statT.Uid
 	entry.GroupID = statT.Gid
 
+	if entry.EntryType != filetype.EntryFile {
+		entry.FileType = filetype.KindType(entry.EntryType)
+		theWorks.Mu.Lock()
+		theWorks.NumOfFiles += 1
+		theWorks.Mu.Unlock()
+		writeJobs <- writeJob{entry: &entry, directory: filepath.Dir(filename)}
+		return
+	}
+
+	// a file whose content cannot be read is still indexed by its metadata, the failure is recorded
+	// next to it
+	var failures []*data.NotAccessedPaths
+	entry.FileType, _ = filetype.Sniff(filename)
+	contentsRead, err := extract.Content(&entry)
+	if err != nil {
+		failures = append(failures, utils.NewFailure(filename, err))
+	}
+	symbols.Collect(&entry)
+	similar.Collect(&entry)
+
 	theWorks.Mu.Lock()
 	theWorks.NumOfFiles += 1
 	if contentsRead {
 		theWorks.NumOfFilesWithContent += 1
 	}
-	theWorks.EntryDetails = append(theWorks.EntryDetails, &entry)
+	theWorks.Mu.Unlock()
+
+	// members go to the writer one by one as they are read, the archive itself follows them
+	if archives.IsArchive(entry.FileType, entry.Name) {
+		err = archives.Expand(&entry, func(member *data.EntryCollection, failure *data.NotAccessedPaths) {
+			theWorks.Mu.Lock()
+			if member.IsDir {
+				theWorks.NumOfDirectories += 1
+			} else {
+				theWorks.NumOfFiles += 1
+			}
+			if len(member.FullTextIndex) > 0 {
+				theWorks.NumOfFilesWithContent += 1
+			}
+			theWorks.Mu.Unlock()
+
+			if failure != nil {
+				recordFailure(failure, "", writeJobs, theWorks)
+			}
+			writeJobs <- writeJob{entry: member}
+		})
+		if err != nil {
+			failures = append(failures, utils.NewFailure(filename, err))
+		}
+	}
+
+	// the file goes last, its directory may count as complete once it is written
+	for _, failure := range failures {
+		recordFailure(failure, "", writeJobs, theWorks)
+	}
+	writeJobs <- writeJob{entry: &entry, directory: filepath.Dir(filename)}
+}
+
+// recordFailure counts a path that could not be read and hands it to the writer. directory is the
+// progress key the traversal dispatched the path under, empty if the failure was not dispatched on
+// its own.
+func recordFailure(failure *data.NotAccessedPaths, directory string, writeJobs chan<- writeJob, theWorks *data.CollectedInfo) {
+	countFailure(failure, theWorks)
+	writeJobs <- writeJob{ignored: failure, directory: directory}
+}
+
+func countFailure(failure *data.NotAccessedPaths, theWorks *data.CollectedInfo) {
+	theWorks.Mu.Lock()
+	theWorks.NumOfIgnoredEntries += 1
+	theWorks.IgnoredByClass[failure.Class] += 1
 	theWorks.Mu.Unlock()
 }
@@ -0,0 +1,38 @@
+package initial
+
+import (
+	"database/sql"
+	"fmt"
+	"icu/db"
+)
+
+// RollbackMain restores the index as it was before the last full scan. The replaced index is kept,
+// so a second rollback undoes the first.
+func RollbackMain(arguments []string) error {
+	if len(arguments) > 0 {
+		return fmt.Errorf("usage: rollback")
+	}
+
+	dbPath, err := db.GetDBPath()
+	if err != nil {
+		return err
+	}
+	con, err := db.CreateConnection(dbPath)
+	if err != nil {
+		return err
+	}
+	defer func(con *sql.DB) {
+		err = db.CloseConnection(con)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}(con)
+
+	err = db.RollbackGeneration(con)
+	if err != nil {
+		return err
+	}
+	fmt.Println("restored the index of the previous full scan, run rollback again to undo")
+
+	return nil
+}
@@ -1,20 +1,32 @@
 package initial
 
 import (
+	"fmt"
 	"io/fs"
-	"log"
-	"os"
 	"path/filepath"
 	"slices"
+	"strings"
 	"icu/data"
 	"icu/utils"
 	"sync"
 )
 
+func isWithin(path string, directory string) bool {
+	return path == directory || strings.HasPrefix(path, directory+string(filepath.Separator))
+}
+
+// traverseDirectory dispatches the read jobs below root. Subtrees a resumed scan already finished
+// are skipped, and every directory is closed in the progress tracker once the walk has left it.
+// Symlinks the policy follows are dispatched as directories.
 func traverseDirectory(
 	root string,
+	policy utils.LinkPolicy,
 	dirJobs chan<- string,
 	fileJobs chan<- string,
+	writeJobs chan<- writeJob,
+	tracker *progress,
+	finished map[string]bool,
+	failed *firstError,
 	wg *sync.WaitGroup,
 	theWorks *data.CollectedInfo,
 ) {
@@ -23,22 +35,39 @@ func traverseDirectory(
 	defer close(dirJobs)
 	defer close(fileJobs)
 
-	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+	open := []string{root}
+	leave := func(path string) {
+		for len(open) > 0 && !isWithin(path, open[len(open)-1]) {
+			tracker.close(open[len(open)-1])
+			open = open[:len(open)-1]
+		}
+	}
+
+	err := utils.Walk(root, policy, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
-			failedPath := data.NotAccessedPaths{Path: path, Err: err.Error()}
-			theWorks.Mu.Lock()
-			theWorks.NumOfIgnoredEntries += 1
-			theWorks.NotRegistered = append(theWorks.NotRegistered, &failedPath)
-			theWorks.Mu.Unlock()
+			if path == root {
+				// a root that cannot be listed would leave an empty index
+				failed.set(fmt.Errorf("could not list %s: %w", root, err))
+				return filepath.SkipDir
+			}
+			leave(path)
+			tracker.dispatched(filepath.Dir(path))
+			recordFailure(utils.NewFailure(path, err), filepath.Dir(path), writeJobs, theWorks)
 			return nil
 		}
 
 		if path == root {
 			return nil
 		}
+		leave(path)
 
-		_, err = os.Stat(path)
+		_, err = utils.StatWalked(path, d)
 		if err != nil {
+			tracker.dispatched(filepath.Dir(path))
+			recordFailure(utils.NewFailure(path, err), filepath.Dir(path), writeJobs, theWorks)
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 
@@ -46,9 +75,16 @@ func traverseDirectory(
 			return filepath.SkipDir
 		}
 
+		if d.IsDir() && finished[path] {
+			return filepath.SkipDir
+		}
+
 		if d.IsDir() {
+			tracker.dispatched(path)
+			open = append(open, path)
 			dirJobs <- path
 		} else {
+			tracker.dispatched(filepath.Dir(path))
 			fileJobs <- path
 		}
 
@@ -56,6 +92,10 @@ func traverseDirectory(
 	})
 
 	if err != nil {
-		log.Printf("Fatal error during directory traversal: %v", err)
+		failed.set(fmt.Errorf("fatal error during directory traversal: %w", err))
+	}
+
+	for i := len(open) - 1; i >= 0; i-- {
+		tracker.close(open[i])
 	}
 }
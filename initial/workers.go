@@ -1,21 +1,137 @@
 package initial
 
 import (
+	"errors"
+	"fmt"
 	"icu/data"
+	"icu/utils"
 	"sync"
 )
 
-func dirWorker(readJobs <-chan string, wg *sync.WaitGroup, theWorks *data.CollectedInfo) {
+// writeJob carries either a read entry or a path that could not be read to the writer stage.
+// directory is the progress key the traversal counted the job against, archive members and
+// failures recorded alongside an entry have none.
+type writeJob struct {
+	entry     *data.EntryCollection
+	ignored   *data.NotAccessedPaths
+	directory string
+}
+
+func dirWorker(readJobs <-chan string, policy utils.LinkPolicy, writeJobs chan<- writeJob, wg *sync.WaitGroup, theWorks *data.CollectedInfo) {
 	defer wg.Done()
 
 	for path := range readJobs {
-		readDir(path, theWorks, false)
+		readDir(path, policy, writeJobs, theWorks, false)
 	}
 }
 
-func fileWorker(readJobs <-chan string, wg *sync.WaitGroup, theWorks *data.CollectedInfo) {
+func fileWorker(readJobs <-chan string, writeJobs chan<- writeJob, wg *sync.WaitGroup, theWorks *data.CollectedInfo) {
 	defer wg.Done()
 	for path := range readJobs {
-		readFile(path, theWorks)
+		readFile(path, writeJobs, theWorks)
+	}
+}
+
+// writeWorker is the writer stage of the full scan. Entries are written as soon as they are read,
+// so only the buffered entries and the current batch are held in memory, whatever the tree size.
+// After every commit the completed directories are recorded as checkpoints. An entry the database
+// rejects on its own is recorded as a failure in its place. Any other failed write or commit stops
+// the writing: the open batch is rolled back, the remaining jobs are drained and the error is kept
+// in failed, so the checkpoints never vouch for entries that were not written.
+func writeWorker(writeJobs <-chan writeJob, writer *data.BatchWriter, tracker *progress, scanID int64, failed *firstError, theWorks *data.CollectedInfo, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	var batch []string
+	for job := range writeJobs {
+		if failed.failed() {
+			continue
+		}
+
+		var err error
+		if job.ignored != nil {
+			err = writer.WriteNotRegisteredEntry(job.ignored)
+		} else {
+			err = writer.WriteEntry(job.entry)
+		}
+		if errors.Is(err, data.ErrEntryRejected) {
+			failure := utils.NewFailure(job.entry.FullPath, err)
+			countFailure(failure, theWorks)
+			err = writer.WriteNotRegisteredEntry(failure)
+		}
+		if err != nil {
+			stopWriting(writer, failed, err)
+			continue
+		}
+		if job.directory != "" {
+			batch = append(batch, job.directory)
+		}
+		if !writer.Pending() {
+			batch, err = checkpoint(writer, tracker, scanID, batch)
+			if err != nil {
+				stopWriting(writer, failed, err)
+			}
+		}
+	}
+	if failed.failed() {
+		return
+	}
+
+	err := writer.Commit()
+	if err == nil {
+		_, err = checkpoint(writer, tracker, scanID, batch)
+	}
+	if err == nil {
+		err = writer.Commit()
+	}
+	if err != nil {
+		stopWriting(writer, failed, err)
+	}
+}
+
+func stopWriting(writer *data.BatchWriter, failed *firstError, err error) {
+	failed.set(err)
+	rollbackErr := writer.Rollback()
+	if rollbackErr != nil {
+		fmt.Println(rollbackErr)
 	}
 }
+
+// checkpoint reports a committed batch and records the directories it completed. The checkpoints
+// are committed with the next batch, after the entries they vouch for.
+func checkpoint(writer *data.BatchWriter, tracker *progress, scanID int64, batch []string) ([]string, error) {
+	tracker.written(batch)
+	for _, directory := range tracker.drain() {
+		err := writer.WriteCheckpoint(scanID, directory)
+		if err != nil {
+			return batch[:0], err
+		}
+	}
+
+	return batch[:0], nil
+}
+
+// firstError keeps the first error of the scan stages that make the new generation unusable.
+type firstError struct {
+	mu  sync.Mutex
+	err error
+}
+
+func (f *firstError) set(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.err == nil {
+		f.err = err
+	}
+}
+
+func (f *firstError) get() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.err
+}
+
+func (f *firstError) failed() bool {
+	return f.get() != nil
+}
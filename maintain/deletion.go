@@ -1,30 +1,46 @@
 package maintain
 
 import (
-	"database/sql"
-	"fmt"
+	"errors"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"icu/archives"
 	"icu/data"
 	"sync"
 )
 
-func checkDelete(entryPath string, con *sql.DB) error {
-	if _, err := os.Stat(entryPath); err != nil {
-		err = data.DeleteEntry(con, entryPath)
-		if err != nil {
-			fmt.Println(err)
-		}
+// checkDelete removes the entry of a path that no longer exists. A path that cannot be checked,
+// e.g. below a directory that became unreadable, keeps its entry and is recorded as a failure.
+// Paths that are not clean, such as a root indexed with a trailing separator by an earlier sync,
+// duplicate the entry of their clean form and are removed as well. The removal itself is left to
+// the writer, in order with everything else the sync writes.
+func checkDelete(indexed data.InodeHeader, writeJobs chan<- data.WriteJob) {
+	entryPath := indexed.Path
+	if entryPath != filepath.Clean(entryPath) {
+		writeJobs <- data.WriteJob{Deleted: &indexed}
+		return
 	}
 
-	return nil
+	// archive members vanish with their archive, changes inside it are handled by re-expanding
+	statPath := entryPath
+	if archivePath, _, ok := archives.Split(entryPath); ok {
+		statPath = archivePath
+	}
+	_, err := os.Lstat(statPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		writeJobs <- data.WriteJob{Deleted: &indexed}
+	} else if err != nil {
+		recordFailure(writeJobs, statPath, err)
+	}
 }
 
-func traverseIndexedEntries(deletionJobs chan<- string, inodeMappedEntries map[uint64]data.InodeHeader, wg *sync.WaitGroup) error {
+func traverseIndexedEntries(deletionJobs chan<- data.InodeHeader, pathMappedEntries map[string]data.InodeHeader, wg *sync.WaitGroup) error {
 	defer wg.Done()
 	defer close(deletionJobs)
 
-	for _, values := range inodeMappedEntries {
-		deletionJobs <- values.Path
+	for _, indexed := range pathMappedEntries {
+		deletionJobs <- indexed
 	}
 	return nil
 }
@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"icu/config"
 	"icu/data"
 	"icu/db"
+	"icu/utils"
 	"sync"
 )
 
@@ -15,6 +17,7 @@ const (
 	scanJobBufferSize     = 100
 	readJobBufferSize     = 500
 	newDirJobBufferSize   = 100
+	writeJobBufferSize    = 500
 	deletionWorkers       = 20
 	entryScanners         = 20
 	entryReaders          = 80
@@ -22,6 +25,15 @@ const (
 )
 
 func orchestrateScan(startPath string) error {
+	// entries are looked up by path, which the full scan stores without a trailing separator
+	startPath = filepath.Clean(startPath)
+	rootPath := filepath.Clean(longScope)
+
+	loadedConfig, err := config.Load()
+	if err != nil {
+		return err
+	}
+
 	homePath, err := os.UserHomeDir()
 	if err != nil {
 		return err
@@ -39,47 +51,70 @@ func orchestrateScan(startPath string) error {
 		}
 	}(con)
 
-	inodeMappedEntries, err := data.GetInodeMappedEntries(con)
+	pathMappedEntries, err := data.GetPathMappedEntries(con)
 	if err != nil {
 		fmt.Println(err)
 	}
+	indexedDirectories := make(map[data.FileID]string)
+	for path, indexed := range pathMappedEntries {
+		if indexed.IsDir {
+			indexedDirectories[indexed.ID()] = path
+		}
+	}
 
-	deletionJobs := make(chan string, deletionJobBufferSize)
+	deletionJobs := make(chan data.InodeHeader, deletionJobBufferSize)
 	scanJobs := make(chan data.InodeHeader, scanJobBufferSize)
 	newDirJobs := make(chan string, newDirJobBufferSize)
 	readJobs := make(chan data.SyncJob, readJobBufferSize)
+	writeJobs := make(chan data.WriteJob, writeJobBufferSize)
 
 	var deletionProdWG sync.WaitGroup
 	var deletionWG sync.WaitGroup
 	var producerWG sync.WaitGroup
 	var scannerWG sync.WaitGroup
 	var readerWG sync.WaitGroup
+	var writerWG sync.WaitGroup
+
+	policy := utils.LinkPolicy{
+		Root:               rootPath,
+		Follow:             loadedConfig.Scanning.FollowSymlinks,
+		OneFilesystem:      loadedConfig.Scanning.Root(rootPath).OneFilesystem,
+		IndexedDirectories: indexedDirectories,
+	}
+	failures := make(map[string]int)
+	writer := data.NewBatchWriter(con, data.LiveTables, loadedConfig.Writing.BatchSize, loadedConfig.Writing.LogEntries)
+	writerWG.Add(1)
+	go writeWorker(writeJobs, writer, failures, &writerWG)
 
 	deletionWG.Add(deletionWorkers)
 	for i := 0; i < deletionWorkers; i++ {
-		go deletionWorker(deletionJobs, con, &deletionWG)
+		go deletionWorker(deletionJobs, writeJobs, &deletionWG)
 	}
 
+	// deleted paths reach the writer before anything is read, so a renamed file is indexed as a
+	// new entry under its new path and never races with the deletion of its old one
 	deletionProdWG.Add(1)
-	traverseIndexedEntries(deletionJobs, inodeMappedEntries, &deletionProdWG)
+	traverseIndexedEntries(deletionJobs, pathMappedEntries, &deletionProdWG)
+	deletionProdWG.Wait()
+	deletionWG.Wait()
 
 	scannerWG.Add(entryScanners)
 	for i := 0; i < entryScanners; i += 1 {
-		go scanWorker(scanJobs, readJobs, inodeMappedEntries, &scannerWG)
+		go scanWorker(scanJobs, readJobs, writeJobs, policy, pathMappedEntries, &scannerWG)
 	}
 
 	scannerWG.Add(newDirWorkers)
 	for i := 0; i < newDirWorkers; i += 1 {
-		go newDirWorker(newDirJobs, readJobs, con, &scannerWG)
+		go newDirWorker(newDirJobs, readJobs, writeJobs, policy, pathMappedEntries, &scannerWG)
 	}
 
 	readerWG.Add(entryReaders)
 	for i := 0; i < entryReaders; i += 1 {
-		go readWorker(readJobs, con, &readerWG)
+		go readWorker(readJobs, writeJobs, policy, con, &readerWG)
 	}
 
 	producerWG.Add(1)
-	go traverseDirectories(scanJobs, newDirJobs, readJobs, startPath, inodeMappedEntries, &producerWG)
+	go traverseDirectories(scanJobs, newDirJobs, readJobs, writeJobs, policy, startPath, pathMappedEntries, &producerWG)
 
 	producerWG.Wait()
 	close(scanJobs)
@@ -89,8 +124,12 @@ func orchestrateScan(startPath string) error {
 	close(readJobs)
 
 	readerWG.Wait()
-	deletionProdWG.Wait()
-	deletionWG.Wait()
+	close(writeJobs)
+	writerWG.Wait()
+
+	if len(failures) > 0 {
+		fmt.Println(utils.FailureSummary(failures))
+	}
 
 	return nil
 }
@@ -1,28 +1,33 @@
 package maintain
 
 import (
-	"bytes"
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
-	"os"
 	"path/filepath"
-	"regexp"
-	"slices"
+	"icu/archives"
 	"icu/data"
+	"icu/dupes"
+	"icu/extract"
+	"icu/filetype"
+	"icu/similar"
+	"icu/symbols"
 	"icu/utils"
 	"syscall"
 	"time"
 )
 
-func readEntry(syncJob data.SyncJob, con *sql.DB) {
-	entryStat, err := os.Stat(syncJob.Path)
+func readEntry(syncJob data.SyncJob, policy utils.LinkPolicy, con *sql.DB, writeJobs chan<- data.WriteJob) {
+	entry := data.EntryCollection{}
+	entryStat, err := policy.StatEntry(syncJob.Path, &entry)
 	if err != nil {
-		fmt.Println(err)
+		recordFailure(writeJobs, syncJob.Path, err)
+		return
 	}
 
-	entry := data.EntryCollection{}
+	var failures []*data.NotAccessedPaths
 
+	entry.EntryID = syncJob.EntryID
 	entry.FullPath = syncJob.Path
 	entry.ParentDirID = filepath.Dir(syncJob.Path)
 	entry.Name = filepath.Base(syncJob.Path)
@@ -31,62 +36,182 @@ func readEntry(syncJob data.SyncJob, con *sql.DB) {
 
 	statT := entryStat.Sys().(*syscall.Stat_t)
 
+	entry.Device = uint64(statT.Dev)
 	entry.Inode = statT.Ino
+	entry.Nlink = uint64(statT.Nlink)
 	entry.ModificationTime = time.Unix(statT.Mtim.Sec, statT.Mtim.Nsec)
 	entry.AccessTime = time.Unix(statT.Atim.Sec, statT.Atim.Nsec)
 	entry.MetaDataChangeTime = time.Unix(statT.Ctim.Sec, statT.Ctim.Nsec)
 
 	entry.OwnerID = statT.Uid
 	entry.GroupID = statT.Gid
+	entry.Extension = filepath.Ext(entry.Name)
 
-	if !entryStat.IsDir() {
-		if slices.Contains(utils.ContentFiles, filepath.Ext(syncJob.Path)) && syncJob.IsContentChange {
-			contents, err := os.ReadFile(syncJob.Path)
+	if entryStat.IsDir() {
+		entry.FileType = filetype.Directory
+	} else if entry.EntryType != filetype.EntryFile {
+		// links and special files are never opened
+		entry.FileType = filetype.KindType(entry.EntryType)
+	} else if syncJob.IsContentChange || !syncJob.IsIndexed {
+		// the type only changes with the content, metadata-only updates keep the stored one
+		entry.FileType, _ = filetype.Sniff(syncJob.Path)
+		if syncJob.IsContentChange {
+			_, err = extract.Content(&entry)
 			if err != nil {
-				log.Fatal(err)
+				failures = append(failures, utils.NewFailure(syncJob.Path, err))
 			}
-			lineCountTotal := bytes.Count(contents, []byte("\n"))
-			blankLines := bytes.Count(contents, []byte("\n\n"))
-			lineCountWithContent := lineCountTotal - blankLines
-
-			if len(contents) < 500 {
-				entry.ContentSnippet = contents
-			} else {
-				entry.ContentSnippet = contents[:500]
+			symbols.Collect(&entry)
+			similar.Collect(&entry)
+
+			// a hash only matters when another file has the same size, dupes fills in the rest
+			sameSize := 0
+			if entry.Size > 0 {
+				sameSize, err = data.CountEntriesOfSize(con, entry.Size, entry.ID())
+			}
+			if err != nil {
+				fmt.Println(err)
+			} else if sameSize > 0 {
+				entry.ContentHash, err = dupes.HashFile(syncJob.Path)
+				if err != nil {
+					fmt.Println(err)
+				}
 			}
+		}
+	}
 
-			contents = bytes.ReplaceAll(contents, []byte("\n"), []byte(" "))
-			contents = bytes.ReplaceAll(contents, []byte("\r"), []byte(" "))
-			contents = bytes.ReplaceAll(contents, []byte("\t"), []byte(" "))
+	writeJob := data.WriteJob{SyncJob: syncJob, Entry: &entry}
+	if entry.EntryType == filetype.EntryFile && archives.HasArchiveName(entry.Name) {
+		if entry.FileType == "" {
+			entry.FileType, _ = filetype.Sniff(syncJob.Path)
+		}
+		if archives.IsArchive(entry.FileType, entry.Name) {
+			err = syncArchive(con, &entry, syncJob.IsContentChange || !syncJob.IsIndexed, writeJobs)
+			if err != nil {
+				failures = append(failures, utils.NewFailure(entry.FullPath, err))
+			}
+		}
+	}
+	writeJob.Failures = failures
 
-			regExCleanup := regexp.MustCompile(`[\p{C}\p{Zl}\p{Zp}]`)
-			contents = regExCleanup.ReplaceAll(contents, []byte(" "))
-			contents = regexp.MustCompile(`\s+`).ReplaceAll(contents, []byte(" "))
+	writeJobs <- writeJob
+}
 
-			entry.FullTextIndex = contents
-			entry.LineCountTotal = lineCountTotal
-			entry.LineCountWithContent = lineCountWithContent
-		}
+// writeEntry applies a job to the index and counts its failures per error class in failures. An
+// entry the database rejects is recorded as a failure of its own.
+func writeEntry(writeJob data.WriteJob, writer *data.BatchWriter, failures map[string]int) {
+	for _, failure := range writeJob.Failures {
+		failures[failure.Class] += 1
+	}
+	err := writeFailures(writeJob, writer)
+	if err != nil {
+		fmt.Println(err)
 	}
 
-	entryCollection := make([]*data.EntryCollection, 1)
-	entryCollection[0] = &entry
-	if !syncJob.IsIndexed {
-		err := data.WriteFullEntries(con, entryCollection)
+	if writeJob.Deleted != nil {
+		deleteEntry(*writeJob.Deleted, writer)
+	}
+	if writeJob.ExpandedArchive != "" {
+		err = writer.DeleteArchiveMembers(writeJob.ExpandedArchive)
 		if err != nil {
-			fmt.Println("error writing: ", entry.FullPath, err)
+			fmt.Println(err)
 		}
+	}
+
+	entry := writeJob.Entry
+	if entry == nil {
 		return
 	}
-	if syncJob.IsContentChange {
-		err := data.UpdateEntriesWithContent(con, entryCollection)
+	switch {
+	case !writeJob.SyncJob.IsIndexed:
+		err = writer.WriteEntry(entry)
+	case writeJob.SyncJob.IsContentChange:
+		err = writer.UpdateEntryWithContent(entry)
+	default:
+		err = writer.UpdateEntryWithoutContent(entry)
+	}
+	if errors.Is(err, data.ErrEntryRejected) {
+		failure := utils.NewFailure(entry.FullPath, err)
+		failures[failure.Class] += 1
+		err = writer.WriteNotRegisteredEntry(failure)
+	}
+	if err != nil {
+		fmt.Println("error writing: ", entry.FullPath, err)
+	}
+	// the other paths of a hard linked file carry the link count too
+	if !entry.IsDir && entry.Nlink > 1 {
+		err = writer.UpdateLinkCount(entry.ID(), entry.Nlink)
 		if err != nil {
-			fmt.Println("error updating: ", entry.FullPath, err)
+			fmt.Println(err)
 		}
-		return
 	}
-	err = data.UpdateEntriesWithoutContent(con, entryCollection)
+}
+
+// deleteEntry removes the entry of a deleted path along with the failure recorded for it, the
+// other hard links of its file lose a link.
+func deleteEntry(deleted data.InodeHeader, writer *data.BatchWriter) {
+	err := writer.RemoveLink(deleted)
+	if err == nil {
+		err = writer.DeleteEntry(deleted.EntryID)
+	}
+	if err == nil {
+		err = writer.DeleteNotRegisteredEntry(deleted.Path)
+	}
 	if err != nil {
-		fmt.Println("error updating: ", entry.FullPath, err)
+		fmt.Println(err)
+	}
+}
+
+// writeFailures replaces the failures recorded for the paths of a job. A path that was read
+// without failure loses the failure an earlier sync recorded for it. Metadata-only updates keep it,
+// since the content was not read again, and so do directories, whose listing fails apart from
+// their read.
+func writeFailures(writeJob data.WriteJob, writer *data.BatchWriter) error {
+	failed := make(map[string]bool)
+	for _, failure := range writeJob.Failures {
+		if !failed[failure.Path] {
+			failed[failure.Path] = true
+			err := writer.DeleteNotRegisteredEntry(failure.Path)
+			if err != nil {
+				return err
+			}
+		}
+		err := writer.WriteNotRegisteredEntry(failure)
+		if err != nil {
+			return err
+		}
+	}
+
+	if writeJob.Entry == nil || failed[writeJob.Entry.FullPath] {
+		return nil
+	}
+	if !writeJob.Entry.IsDir && (writeJob.SyncJob.IsContentChange || !writeJob.SyncJob.IsIndexed) {
+		return writer.DeleteNotRegisteredEntry(writeJob.Entry.FullPath)
 	}
+
+	return nil
+}
+
+// syncArchive re-expands an archive whose content changed. An unchanged archive is only expanded
+// when none of its members are indexed under its current path, which happens after a rename. The
+// old members are cleared first, the new ones are handed to the writer as they are read.
+func syncArchive(con *sql.DB, entry *data.EntryCollection, contentChanged bool, writeJobs chan<- data.WriteJob) error {
+	if !contentChanged {
+		memberCount, err := data.CountArchiveMembers(con, entry.FullPath)
+		if err != nil {
+			fmt.Println(err)
+			return nil
+		}
+		if memberCount > 0 {
+			return nil
+		}
+	}
+
+	writeJobs <- data.WriteJob{ExpandedArchive: entry.FullPath}
+	return archives.Expand(entry, func(member *data.EntryCollection, failure *data.NotAccessedPaths) {
+		job := data.WriteJob{SyncJob: data.SyncJob{Path: member.FullPath}, Entry: member}
+		if failure != nil {
+			job.Failures = []*data.NotAccessedPaths{failure}
+		}
+		writeJobs <- job
+	})
 }
@@ -7,11 +7,9 @@ import (
 	"slices"
 	"icu/data"
 	"icu/utils"
-	"syscall"
-	"time"
 )
 
-func scanUpdatedDir(readJobs chan<- data.SyncJob, dirPath string, inodeMappedEntries map[uint64]data.InodeHeader) error {
+func scanUpdatedDir(readJobs chan<- data.SyncJob, writeJobs chan<- data.WriteJob, policy utils.LinkPolicy, dirPath string, pathMappedEntries map[string]data.InodeHeader) error {
 	fileSysEntries, err := os.ReadDir(dirPath)
 	if err != nil {
 		return fmt.Errorf("failed to list entries in directory: %s\n%w", dirPath, err)
@@ -20,32 +18,21 @@ func scanUpdatedDir(readJobs chan<- data.SyncJob, dirPath string, inodeMappedEnt
 	for _, entry := range fileSysEntries {
 		filePath := filepath.Join(dirPath, entry.Name())
 
-		entryStat, err := os.Stat(filePath)
+		entryStat, err := policy.StatEntry(filePath, &data.EntryCollection{})
 		if err != nil {
-			return err
+			recordFailure(writeJobs, filePath, err)
+			continue
 		}
 
 		if entryStat.IsDir() && slices.Contains(utils.ExcludedEntries, filepath.Base(filePath)) {
 			continue
 		}
 
-		entryStatT := entryStat.Sys().(*syscall.Stat_t)
-		entryMtim := time.Unix(entryStatT.Mtim.Sec, entryStatT.Mtim.Nsec)
-
-		if inode, ok := inodeMappedEntries[entryStatT.Ino]; !ok {
-			if !entryStat.IsDir() {
-				syncJob := data.SyncJob{Path: filePath, IsIndexed: false, IsContentChange: true}
-				readJobs <- syncJob
-			}
-		} else {
-			if !entryMtim.Equal(inode.ModificationTime) {
-				syncJob := data.SyncJob{Path: filePath, IsIndexed: true, IsContentChange: !entry.IsDir()}
-				readJobs <- syncJob
-			} else {
-				syncJob := data.SyncJob{Path: filePath, IsIndexed: true, IsContentChange: false}
-				readJobs <- syncJob
-			}
+		// new directories are walked by the new directory workers
+		if _, ok := pathMappedEntries[filePath]; !ok && entryStat.IsDir() {
+			continue
 		}
+		readJobs <- newSyncJob(filePath, entryStat, pathMappedEntries)
 	}
 
 	return nil
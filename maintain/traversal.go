@@ -1,11 +1,9 @@
 package maintain
 
 import (
-	"database/sql"
 	"fmt"
 	"io/fs"
 	"log"
-	"os"
 	"path/filepath"
 	"slices"
 	"icu/data"
@@ -15,92 +13,85 @@ import (
 	"time"
 )
 
-func traverseNewDir(readJobs chan<- data.SyncJob, startPath string, con *sql.DB) error {
+func traverseNewDir(readJobs chan<- data.SyncJob, writeJobs chan<- data.WriteJob, policy utils.LinkPolicy, startPath string, pathMappedEntries map[string]data.InodeHeader) error {
 	fmt.Println("Traversing new dir: ", startPath)
-	inodeMappedEntries, err := data.GetInodeMappedEntries(con)
-	if err != nil {
-		return err
-	}
-	err = filepath.WalkDir(startPath, func(path string, d fs.DirEntry, err error) error {
+	return utils.Walk(startPath, policy, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
-			return err
+			recordFailure(writeJobs, path, err)
+			return nil
 		}
 
 		if d.IsDir() && slices.Contains(utils.ExcludedEntries, filepath.Base(path)) {
 			return filepath.SkipDir
 		}
 
-		entryStat, err := os.Stat(path)
+		entryStat, err := utils.StatWalked(path, d)
 		if err != nil {
-			return err
+			return skipFailed(writeJobs, path, d, err)
 		}
 
-		var syncJob data.SyncJob
-		entryStatT := entryStat.Sys().(*syscall.Stat_t)
-		if inode, ok := inodeMappedEntries[entryStatT.Ino]; ok {
-			entryMtim := time.Unix(entryStatT.Mtim.Sec, entryStatT.Mtim.Nsec)
-			indexedMtim := inode.ModificationTime
-			if entryStat.IsDir() || entryMtim.Equal(indexedMtim) {
-				syncJob = data.SyncJob{Path: path, IsIndexed: true, IsContentChange: false}
-			} else {
-				syncJob = data.SyncJob{Path: path, IsIndexed: true, IsContentChange: true}
-			}
-		} else {
-			if entryStat.IsDir() {
-				syncJob = data.SyncJob{Path: path, IsIndexed: false, IsContentChange: false}
-			} else {
-				syncJob = data.SyncJob{Path: path, IsIndexed: false, IsContentChange: true}
-			}
-		}
-		readJobs <- syncJob
+		readJobs <- newSyncJob(path, entryStat, pathMappedEntries)
 		return nil
 	})
+}
 
-	return nil
+// newSyncJob compares a path with its indexed state. Paths are matched rather than files, so a
+// new hard link is indexed as a new entry, and a file replaced under the same path, as editors do
+// when saving or a mount does, updates the entry of that path.
+func newSyncJob(path string, entryStat fs.FileInfo, pathMappedEntries map[string]data.InodeHeader) data.SyncJob {
+	indexed, ok := pathMappedEntries[path]
+	if !ok {
+		return data.SyncJob{Path: path, IsIndexed: false, IsContentChange: !entryStat.IsDir()}
+	}
+
+	statT := entryStat.Sys().(*syscall.Stat_t)
+	mTim := time.Unix(statT.Mtim.Sec, statT.Mtim.Nsec)
+	changed := fileID(statT) != indexed.ID() || !mTim.Equal(indexed.ModificationTime) || entryStat.Size() != indexed.Size
+
+	return data.SyncJob{Path: path, EntryID: indexed.EntryID, IsIndexed: true, IsContentChange: changed && !entryStat.IsDir()}
 }
 
 func traverseDirectories(
 	scanJobs chan<- data.InodeHeader,
 	newDirJobs chan<- string,
 	readJobs chan<- data.SyncJob,
+	writeJobs chan<- data.WriteJob,
+	policy utils.LinkPolicy,
 	startPath string,
-	inodeMappedEntries map[uint64]data.InodeHeader,
+	pathMappedEntries map[string]data.InodeHeader,
 	wg *sync.WaitGroup,
 ) {
 	defer wg.Done()
 
-	err := filepath.WalkDir(startPath, func(path string, d fs.DirEntry, err error) error {
+	err := utils.Walk(startPath, policy, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
-			return err
+			recordFailure(writeJobs, path, err)
+			return nil
 		}
 
 		if d.IsDir() && slices.Contains(utils.ExcludedEntries, filepath.Base(path)) {
 			return filepath.SkipDir
 		}
 
-		entryStat, err := os.Stat(path)
+		entryStat, err := utils.StatWalked(path, d)
 		if err != nil {
-			return err
+			return skipFailed(writeJobs, path, d, err)
 		}
 
 		statT := entryStat.Sys().(*syscall.Stat_t)
 
 		if d.IsDir() {
-			if _, ok := inodeMappedEntries[statT.Ino]; !ok {
+			indexed, ok := pathMappedEntries[path]
+			if !ok {
+				// the new directory worker walks the whole subtree
 				newDirJobs <- path
-			} else {
-				for inode, values := range inodeMappedEntries {
-					if inode != statT.Ino {
-						continue
-					}
-					mTim := time.Unix(statT.Mtim.Sec, statT.Mtim.Nsec)
-					cTim := time.Unix(statT.Ctim.Sec, statT.Ctim.Nsec)
-					if !values.ModificationTime.Equal(mTim) || !values.MetaDataChangeTime.Equal(cTim) {
-						readJobs <- data.SyncJob{Path: path, IsIndexed: true, IsContentChange: false}
-						scanJobs <- values
-						continue
-					}
-				}
+				return filepath.SkipDir
+			}
+			mTim := time.Unix(statT.Mtim.Sec, statT.Mtim.Nsec)
+			cTim := time.Unix(statT.Ctim.Sec, statT.Ctim.Nsec)
+			if fileID(statT) != indexed.ID() || !indexed.ModificationTime.Equal(mTim) || !indexed.MetaDataChangeTime.Equal(cTim) {
+				readJobs <- data.SyncJob{Path: path, EntryID: indexed.EntryID, IsIndexed: true, IsContentChange: false}
+				scanJobs <- indexed
 			}
 		}
 
@@ -111,3 +102,18 @@ func traverseDirectories(
 		log.Printf("Fatal error during directory traversal: %v", err)
 	}
 }
+
+// skipFailed records a path that vanished or became unreadable between listing and stat, and keeps
+// the walk out of it.
+func skipFailed(writeJobs chan<- data.WriteJob, path string, d fs.DirEntry, err error) error {
+	recordFailure(writeJobs, path, err)
+	if d.IsDir() {
+		return filepath.SkipDir
+	}
+
+	return nil
+}
+
+func fileID(statT *syscall.Stat_t) data.FileID {
+	return data.FileID{Device: uint64(statT.Dev), Inode: statT.Ino}
+}
@@ -2,41 +2,65 @@ package maintain
 
 import (
 	"database/sql"
-	"log"
+	"fmt"
 	"icu/data"
+	"icu/utils"
 	"sync"
 )
 
-func scanWorker(scanJobs <-chan data.InodeHeader, readJobs chan<- data.SyncJob, inodeMappedEntries map[uint64]data.InodeHeader, wg *sync.WaitGroup) {
+func scanWorker(scanJobs <-chan data.InodeHeader, readJobs chan<- data.SyncJob, writeJobs chan<- data.WriteJob, policy utils.LinkPolicy, pathMappedEntries map[string]data.InodeHeader, wg *sync.WaitGroup) {
 	defer wg.Done()
 	for job := range scanJobs {
-		err := scanUpdatedDir(readJobs, job.Path, inodeMappedEntries)
+		err := scanUpdatedDir(readJobs, writeJobs, policy, job.Path, pathMappedEntries)
 		if err != nil {
-			log.Fatal(err)
+			recordFailure(writeJobs, job.Path, err)
 		}
 	}
 }
-func readWorker(readJobs <-chan data.SyncJob, con *sql.DB, wg *sync.WaitGroup) {
+func readWorker(readJobs <-chan data.SyncJob, writeJobs chan<- data.WriteJob, policy utils.LinkPolicy, con *sql.DB, wg *sync.WaitGroup) {
 	defer wg.Done()
 	for job := range readJobs {
-		readEntry(job, con)
+		readEntry(job, policy, con, writeJobs)
 	}
 }
-func newDirWorker(newDirJobs <-chan string, readJobs chan<- data.SyncJob, con *sql.DB, wg *sync.WaitGroup) {
+
+// writeWorker is the only writer of read entries, so sqlite never sees competing transactions
+// from the readers. The batch is committed when it is full or when no more entries are waiting.
+// Recorded failures are counted per error class in failures.
+func writeWorker(writeJobs <-chan data.WriteJob, writer *data.BatchWriter, failures map[string]int, wg *sync.WaitGroup) {
 	defer wg.Done()
-	for path := range newDirJobs {
-		err := traverseNewDir(readJobs, path, con)
-		if err != nil {
-			log.Fatal(err)
+	for job := range writeJobs {
+		writeEntry(job, writer, failures)
+		if len(writeJobs) == 0 && writer.Pending() {
+			err := writer.Commit()
+			if err != nil {
+				fmt.Println(err)
+			}
 		}
 	}
+	err := writer.Commit()
+	if err != nil {
+		fmt.Println(err)
+	}
 }
-func deletionWorker(delJobs <-chan string, con *sql.DB, wg *sync.WaitGroup) {
+func newDirWorker(newDirJobs <-chan string, readJobs chan<- data.SyncJob, writeJobs chan<- data.WriteJob, policy utils.LinkPolicy, pathMappedEntries map[string]data.InodeHeader, wg *sync.WaitGroup) {
 	defer wg.Done()
-	for path := range delJobs {
-		err := checkDelete(path, con)
+	for path := range newDirJobs {
+		err := traverseNewDir(readJobs, writeJobs, policy, path, pathMappedEntries)
 		if err != nil {
-			log.Fatal(err)
+			fmt.Println(err)
 		}
 	}
 }
+func deletionWorker(delJobs <-chan data.InodeHeader, writeJobs chan<- data.WriteJob, wg *sync.WaitGroup) {
+	defer wg.Done()
+	for indexed := range delJobs {
+		checkDelete(indexed, writeJobs)
+	}
+}
+
+// recordFailure hands a path that could not be read to the writer, which stores it with the
+// ignored entries. The sync carries on with the next path.
+func recordFailure(writeJobs chan<- data.WriteJob, path string, err error) {
+	writeJobs <- data.WriteJob{Failures: []*data.NotAccessedPaths{utils.NewFailure(path, err)}}
+}
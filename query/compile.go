@@ -0,0 +1,326 @@
+package query
+
+import (
+	"fmt"
+	"icu/utils"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Compiled struct {
+	Where     string // sql condition over the entries table aliased as e
+	Args      []any
+	RankMatch string     // fts5 match expression of all positive text terms, used for ranking
+	TextTerms []TextTerm // positive text terms, used for locating matches in content
+}
+
+type TextTerm struct {
+	Value  string
+	Phrase bool
+}
+
+var (
+	durationPattern = regexp.MustCompile(`^(\d+)(s|m|h|d|w|y)$`)
+	dateLayouts     = []string{"2006-01-02", "2006-01-02T15:04", "2006-01-02T15:04:05"}
+)
+
+type compiler struct {
+	input     string
+	args      []any
+	rankTerms []string
+	textTerms []TextTerm
+	now       time.Time
+}
+
+func Compile(input string) (Compiled, error) {
+	root, err := parse(input)
+	if err != nil {
+		return Compiled{}, err
+	}
+	if root == nil {
+		return Compiled{Where: "1 = 1"}, nil
+	}
+
+	c := compiler{input: input, now: time.Now()}
+	where, err := c.compile(root, false)
+	if err != nil {
+		return Compiled{}, err
+	}
+
+	return Compiled{
+		Where:     where,
+		Args:      c.args,
+		RankMatch: strings.Join(c.rankTerms, " OR "),
+		TextTerms: c.textTerms,
+	}, nil
+}
+
+func (c *compiler) errorAt(pos int, msg string) error {
+	return &ParseError{Query: c.input, Pos: pos, Msg: msg}
+}
+
+func (c *compiler) compile(n node, negated bool) (string, error) {
+	switch n := n.(type) {
+	case andNode:
+		return c.compileBinary(n.left, n.right, "and", negated)
+	case orNode:
+		return c.compileBinary(n.left, n.right, "or", negated)
+	case notNode:
+		operand, err := c.compile(n.operand, !negated)
+		if err != nil {
+			return "", err
+		}
+		return "not " + operand, nil
+	case textTerm:
+		match := FullTextTerm(n.value, n.phrase)
+		if !negated {
+			c.rankTerms = append(c.rankTerms, match)
+			c.textTerms = append(c.textTerms, TextTerm{Value: n.value, Phrase: n.phrase})
+		}
+		c.args = append(c.args, match)
+		return "e.entry_id in (select rowid from entries_fts where entries_fts match ?)", nil
+	case fieldTerm:
+		return c.compileField(n)
+	default:
+		return "", fmt.Errorf("unsupported query node %T", n)
+	}
+}
+
+func (c *compiler) compileBinary(left, right node, operator string, negated bool) (string, error) {
+	leftSQL, err := c.compile(left, negated)
+	if err != nil {
+		return "", err
+	}
+	rightSQL, err := c.compile(right, negated)
+	if err != nil {
+		return "", err
+	}
+
+	return "(" + leftSQL + " " + operator + " " + rightSQL + ")", nil
+}
+
+func FullTextTerm(value string, phrase bool) string {
+	quoted := `"` + strings.ReplaceAll(value, `"`, `""`) + `"`
+	if phrase {
+		return quoted
+	}
+
+	return quoted + "*"
+}
+
+func (c *compiler) compileField(term fieldTerm) (string, error) {
+	switch term.field {
+	case "ext":
+		if err := c.requireOps(term, ":", "="); err != nil {
+			return "", err
+		}
+		extension := strings.ToLower(term.value)
+		if !strings.HasPrefix(extension, ".") {
+			extension = "." + extension
+		}
+		c.args = append(c.args, extension)
+		return "lower(e.extension) = ?", nil
+
+	case "type":
+		if err := c.requireOps(term, ":", "="); err != nil {
+			return "", err
+		}
+		mimeType := strings.ToLower(strings.TrimSuffix(term.value, "/*"))
+		if strings.Contains(mimeType, "/") {
+			c.args = append(c.args, mimeType)
+			return "e.filetype = ?", nil
+		}
+		// a bare family such as image matches every subtype
+		family := mimeType + "/"
+		c.args = append(c.args, len(family), family)
+		return "substr(e.filetype, 1, ?) = ?", nil
+
+	case "size":
+		size, err := c.parseSize(term)
+		if err != nil {
+			return "", err
+		}
+		c.args = append(c.args, size)
+		return "e.size " + sqlOperator(term.op) + " ?", nil
+
+	case "modified":
+		return c.compileTime(term, "e.modification_time")
+
+	case "accessed":
+		return c.compileTime(term, "e.access_time")
+
+	case "owner", "group":
+		id, err := strconv.ParseUint(term.value, 10, 32)
+		if err != nil {
+			return "", c.errorAt(term.valuePos, fmt.Sprintf("%s expects a numeric id, got %q", term.field, term.value))
+		}
+		column := "e.owner_id"
+		if term.field == "group" {
+			column = "e.group_id"
+		}
+		c.args = append(c.args, id)
+		return column + " " + sqlOperator(term.op) + " ?", nil
+
+	case "is":
+		if err := c.requireOps(term, ":", "="); err != nil {
+			return "", err
+		}
+		switch strings.ToLower(term.value) {
+		case "dir", "directory":
+			return "e.is_dir = 1", nil
+		case "file":
+			return "e.is_dir = 0 and coalesce(e.entry_type, 'file') = 'file'", nil
+		case "symlink", "link":
+			return "e.entry_type = 'symlink'", nil
+		case "fifo", "pipe":
+			return "e.entry_type = 'fifo'", nil
+		case "socket":
+			return "e.entry_type = 'socket'", nil
+		case "device":
+			return "e.entry_type in ('block_device', 'char_device')", nil
+		default:
+			return "", c.errorAt(term.valuePos, fmt.Sprintf("is expects dir, file, symlink, fifo, socket or device, got %q", term.value))
+		}
+
+	case "path":
+		if err := c.requireOps(term, ":", "="); err != nil {
+			return "", err
+		}
+		prefix, err := utils.ExpandPath(term.value)
+		if err != nil {
+			return "", c.errorAt(term.valuePos, err.Error())
+		}
+		directory := strings.TrimSuffix(prefix, "/") + "/"
+		// an archive counts as a directory holding its members
+		c.args = append(c.args, prefix, len(directory), directory, prefix)
+		return "(e.path = ? or substr(e.path, 1, ?) = ? or e.archive_path = ?)", nil
+
+	case "name":
+		if err := c.requireOps(term, ":", "="); err != nil {
+			return "", err
+		}
+		if strings.ContainsAny(term.value, "*?[") {
+			c.args = append(c.args, term.value)
+			return "e.name glob ?", nil
+		}
+		c.args = append(c.args, "%"+escapeLike(term.value)+"%")
+		return `e.name like ? escape '\'`, nil
+
+	case "tag":
+		if err := c.requireOps(term, ":", "="); err != nil {
+			return "", err
+		}
+		tag, err := utils.NormalizeTag(term.value)
+		if err != nil {
+			return "", c.errorAt(term.valuePos, err.Error())
+		}
+		// a tag matches itself and all of its children, either on the entry itself or inherited
+		// from any tagged directory above it
+		children := tag + "/"
+		c.args = append(c.args, tag, len(children), children, tag, len(children), children)
+		return `((e.device, e.inode) in (select te.device, te.inode from tagged_entries te
+					join tags t on t.tag_id = te.tag_id
+					where t.name = ? or substr(t.name, 1, ?) = ?)
+				or exists (select 1 from tagged_entries te
+					join tags t on t.tag_id = te.tag_id
+					join entries d on d.device = te.device and d.inode = te.inode
+					where d.is_dir = 1 and t.inherit = 1
+						and (t.name = ? or substr(t.name, 1, ?) = ?)
+						and substr(e.path, 1, length(d.path) + 1) = d.path || '/'))`, nil
+	}
+
+	return "", c.errorAt(term.pos, fmt.Sprintf("unknown field %q", term.field))
+}
+
+func (c *compiler) requireOps(term fieldTerm, allowed ...string) error {
+	for _, op := range allowed {
+		if term.op == op {
+			return nil
+		}
+	}
+
+	return c.errorAt(term.valuePos-len(term.op), fmt.Sprintf("%s does not support the %q operator", term.field, term.op))
+}
+
+func (c *compiler) parseSize(term fieldTerm) (int64, error) {
+	size, err := utils.ParseSize(term.value)
+	if err != nil {
+		return 0, c.errorAt(term.valuePos, err.Error())
+	}
+
+	return size, nil
+}
+
+func (c *compiler) compileTime(term fieldTerm, column string) (string, error) {
+	if match := durationPattern.FindStringSubmatch(term.value); match != nil {
+		amount, _ := strconv.Atoi(match[1])
+		cutoff := c.now.Add(-time.Duration(amount) * durationUnit(match[2]))
+		c.args = append(c.args, cutoff)
+
+		// durations are ages, so "<7d" means newer than seven days ago
+		switch term.op {
+		case ":", "=", "<":
+			return "julianday(" + column + ") > julianday(?)", nil
+		case "<=":
+			return "julianday(" + column + ") >= julianday(?)", nil
+		case ">":
+			return "julianday(" + column + ") < julianday(?)", nil
+		default:
+			return "julianday(" + column + ") <= julianday(?)", nil
+		}
+	}
+
+	for _, layout := range dateLayouts {
+		moment, err := time.ParseInLocation(layout, term.value, time.Local)
+		if err != nil {
+			continue
+		}
+
+		switch term.op {
+		case ":", "=":
+			end := moment.Add(24 * time.Hour)
+			if layout != "2006-01-02" {
+				end = moment.Add(time.Minute)
+			}
+			c.args = append(c.args, moment, end)
+			return "(julianday(" + column + ") >= julianday(?) and julianday(" + column + ") < julianday(?))", nil
+		default:
+			c.args = append(c.args, moment)
+			return "julianday(" + column + ") " + term.op + " julianday(?)", nil
+		}
+	}
+
+	return "", c.errorAt(term.valuePos, fmt.Sprintf("invalid time %q, expected an age like 7d or a date like 2024-01-31", term.value))
+}
+
+func durationUnit(unit string) time.Duration {
+	switch unit {
+	case "s":
+		return time.Second
+	case "m":
+		return time.Minute
+	case "h":
+		return time.Hour
+	case "d":
+		return 24 * time.Hour
+	case "w":
+		return 7 * 24 * time.Hour
+	default:
+		return 365 * 24 * time.Hour
+	}
+}
+
+func sqlOperator(op string) string {
+	if op == ":" {
+		return "="
+	}
+
+	return op
+}
+
+func escapeLike(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return replacer.Replace(value)
+}
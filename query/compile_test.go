@@ -0,0 +1,253 @@
+package query
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const fts = "e.entry_id in (select rowid from entries_fts where entries_fts match ?)"
+
+var now = time.Date(2024, 6, 15, 12, 0, 0, 0, time.Local)
+
+// compileAt compiles input like Compile does, with a fixed clock for the relative times.
+func compileAt(t *testing.T, input string) (string, []any) {
+	t.Helper()
+
+	root, err := parse(input)
+	if err != nil {
+		t.Fatalf("parse(%q): %v", input, err)
+	}
+	c := compiler{input: input, now: now}
+	where, err := c.compile(root, false)
+	if err != nil {
+		t.Fatalf("compile(%q): %v", input, err)
+	}
+
+	return where, c.args
+}
+
+func TestPrecedence(t *testing.T) {
+	tests := []struct {
+		input string
+		where string
+		args  []any
+	}{
+		{"a", fts, []any{`"a"*`}},
+		{"a b", "(" + fts + " and " + fts + ")", []any{`"a"*`, `"b"*`}},
+		{"a AND b", "(" + fts + " and " + fts + ")", []any{`"a"*`, `"b"*`}},
+		{"a OR b", "(" + fts + " or " + fts + ")", []any{`"a"*`, `"b"*`}},
+		{"a b OR c", "((" + fts + " and " + fts + ") or " + fts + ")", []any{`"a"*`, `"b"*`, `"c"*`}},
+		{"a OR b c", "(" + fts + " or (" + fts + " and " + fts + "))", []any{`"a"*`, `"b"*`, `"c"*`}},
+		{"a (b OR c)", "(" + fts + " and (" + fts + " or " + fts + "))", []any{`"a"*`, `"b"*`, `"c"*`}},
+		{"NOT a b", "(not " + fts + " and " + fts + ")", []any{`"a"*`, `"b"*`}},
+		{"-a OR b", "(not " + fts + " or " + fts + ")", []any{`"a"*`, `"b"*`}},
+		{"NOT (a OR b)", "not (" + fts + " or " + fts + ")", []any{`"a"*`, `"b"*`}},
+		{"NOT NOT a", "not not " + fts, []any{`"a"*`}},
+		{`"and" or`, "(" + fts + " and " + fts + ")", []any{`"and"`, `"or"*`}},
+		{`"exact phrase"`, fts, []any{`"exact phrase"`}},
+	}
+
+	for _, test := range tests {
+		where, args := compileAt(t, test.input)
+		if where != test.where {
+			t.Errorf("%q: where\n got %s\nwant %s", test.input, where, test.where)
+		}
+		if !reflect.DeepEqual(args, test.args) {
+			t.Errorf("%q: args got %v, want %v", test.input, args, test.args)
+		}
+	}
+}
+
+func TestRankTerms(t *testing.T) {
+	compiled, err := Compile(`alpha -beta "gamma delta"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := `"alpha"* OR "gamma delta"`; compiled.RankMatch != want {
+		t.Errorf("rank match got %q, want %q", compiled.RankMatch, want)
+	}
+	want := []TextTerm{{Value: "alpha"}, {Value: "gamma delta", Phrase: true}}
+	if !reflect.DeepEqual(compiled.TextTerms, want) {
+		t.Errorf("text terms got %v, want %v", compiled.TextTerms, want)
+	}
+}
+
+func TestEmptyQuery(t *testing.T) {
+	for _, input := range []string{"", "   "} {
+		compiled, err := Compile(input)
+		if err != nil {
+			t.Fatalf("%q: %v", input, err)
+		}
+		if compiled.Where != "1 = 1" || compiled.Args != nil {
+			t.Errorf("%q: got %q %v, want 1 = 1 without args", input, compiled.Where, compiled.Args)
+		}
+	}
+}
+
+func TestFieldPredicates(t *testing.T) {
+	tests := []struct {
+		input string
+		where string
+		args  []any
+	}{
+		{"ext:go", "lower(e.extension) = ?", []any{".go"}},
+		{"ext:.GO", "lower(e.extension) = ?", []any{".go"}},
+		{"type:text/plain", "e.filetype = ?", []any{"text/plain"}},
+		{"type:image", "substr(e.filetype, 1, ?) = ?", []any{6, "image/"}},
+		{"type:image/*", "substr(e.filetype, 1, ?) = ?", []any{6, "image/"}},
+		{"owner:1000", "e.owner_id = ?", []any{uint64(1000)}},
+		{"group>=100", "e.group_id >= ?", []any{uint64(100)}},
+		{"is:dir", "e.is_dir = 1", nil},
+		{"is:file", "e.is_dir = 0 and coalesce(e.entry_type, 'file') = 'file'", nil},
+		{"is:link", "e.entry_type = 'symlink'", nil},
+		{"is:pipe", "e.entry_type = 'fifo'", nil},
+		{"is:socket", "e.entry_type = 'socket'", nil},
+		{"is:device", "e.entry_type in ('block_device', 'char_device')", nil},
+		{"path:/srv/data", "(e.path = ? or substr(e.path, 1, ?) = ? or e.archive_path = ?)",
+			[]any{"/srv/data", 10, "/srv/data/", "/srv/data"}},
+		{"path:/srv/data/", "(e.path = ? or substr(e.path, 1, ?) = ? or e.archive_path = ?)",
+			[]any{"/srv/data", 10, "/srv/data/", "/srv/data"}},
+		{"name:*.txt", "e.name glob ?", []any{"*.txt"}},
+		{"name:50%_off", `e.name like ? escape '\'`, []any{`%50\%\_off%`}},
+		{"Name:report", `e.name like ? escape '\'`, []any{"%report%"}},
+	}
+
+	for _, test := range tests {
+		where, args := compileAt(t, test.input)
+		if where != test.where {
+			t.Errorf("%q: where got %q, want %q", test.input, where, test.where)
+		}
+		if !reflect.DeepEqual(args, test.args) {
+			t.Errorf("%q: args got %#v, want %#v", test.input, args, test.args)
+		}
+	}
+}
+
+func TestTagPredicate(t *testing.T) {
+	_, args := compileAt(t, "tag:Work")
+
+	want := []any{"work", 5, "work/", "work", 5, "work/"}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("args got %#v, want %#v", args, want)
+	}
+}
+
+func TestSizeUnits(t *testing.T) {
+	tests := []struct {
+		input string
+		where string
+		size  int64
+	}{
+		{"size:512", "e.size = ?", 512},
+		{"size>10k", "e.size > ?", 10 << 10},
+		{"size>=10KB", "e.size >= ?", 10 << 10},
+		{"size<1.5mb", "e.size < ?", 3 << 19},
+		{"size<=2MiB", "e.size <= ?", 2 << 20},
+		{"size=1g", "e.size = ?", 1 << 30},
+		{"size>1TB", "e.size > ?", 1 << 40},
+	}
+
+	for _, test := range tests {
+		where, args := compileAt(t, test.input)
+		if where != test.where {
+			t.Errorf("%q: where got %q, want %q", test.input, where, test.where)
+		}
+		if !reflect.DeepEqual(args, []any{test.size}) {
+			t.Errorf("%q: args got %v, want [%d]", test.input, args, test.size)
+		}
+	}
+}
+
+func TestDurationUnits(t *testing.T) {
+	day := 24 * time.Hour
+	tests := []struct {
+		input  string
+		where  string
+		cutoff time.Time
+	}{
+		{"modified:30s", "julianday(e.modification_time) > julianday(?)", now.Add(-30 * time.Second)},
+		{"modified<15m", "julianday(e.modification_time) > julianday(?)", now.Add(-15 * time.Minute)},
+		{"modified<=2h", "julianday(e.modification_time) >= julianday(?)", now.Add(-2 * time.Hour)},
+		{"modified>7d", "julianday(e.modification_time) < julianday(?)", now.Add(-7 * day)},
+		{"accessed>=2w", "julianday(e.access_time) <= julianday(?)", now.Add(-14 * day)},
+		{"accessed=1y", "julianday(e.access_time) > julianday(?)", now.Add(-365 * day)},
+	}
+
+	for _, test := range tests {
+		where, args := compileAt(t, test.input)
+		if where != test.where {
+			t.Errorf("%q: where got %q, want %q", test.input, where, test.where)
+		}
+		if !reflect.DeepEqual(args, []any{test.cutoff}) {
+			t.Errorf("%q: args got %v, want [%v]", test.input, args, test.cutoff)
+		}
+	}
+}
+
+func TestDates(t *testing.T) {
+	day := time.Date(2024, 1, 31, 0, 0, 0, 0, time.Local)
+	minute := time.Date(2024, 1, 31, 9, 30, 0, 0, time.Local)
+	tests := []struct {
+		input string
+		where string
+		args  []any
+	}{
+		{"modified:2024-01-31",
+			"(julianday(e.modification_time) >= julianday(?) and julianday(e.modification_time) < julianday(?))",
+			[]any{day, day.Add(24 * time.Hour)}},
+		{"modified:2024-01-31T09:30",
+			"(julianday(e.modification_time) >= julianday(?) and julianday(e.modification_time) < julianday(?))",
+			[]any{minute, minute.Add(time.Minute)}},
+		{"modified<2024-01-31", "julianday(e.modification_time) < julianday(?)", []any{day}},
+		{"accessed>=2024-01-31T09:30:00", "julianday(e.access_time) >= julianday(?)", []any{minute}},
+	}
+
+	for _, test := range tests {
+		where, args := compileAt(t, test.input)
+		if where != test.where {
+			t.Errorf("%q: where got %q, want %q", test.input, where, test.where)
+		}
+		if !reflect.DeepEqual(args, test.args) {
+			t.Errorf("%q: args got %v, want %v", test.input, args, test.args)
+		}
+	}
+}
+
+func TestErrorPosition(t *testing.T) {
+	tests := []struct {
+		input string
+		pos   int
+		msg   string
+	}{
+		{"a)", 1, "unmatched closing parenthesis"},
+		{"a (b", 2, "missing closing parenthesis"},
+		{"()", 1, "empty parentheses"},
+		{`a "open`, 2, "unterminated phrase"},
+		{`name:"open`, 5, "unterminated quote"},
+		{`""`, 0, "empty phrase"},
+		{"a OR", 4, "unexpected end of query"},
+		{"colour:red", 0, `unknown field "colour"`},
+		{"size:", 5, "missing value for size"},
+		{"size>big", 5, `invalid size "big", expected a number with an optional unit like 10MB`},
+		{"ext>go", 3, `ext does not support the ">" operator`},
+		{"a owner:root", 8, `owner expects a numeric id, got "root"`},
+		{"is:thing", 3, `is expects dir, file, symlink, fifo, socket or device, got "thing"`},
+		{"modified:soon", 9, `invalid time "soon", expected an age like 7d or a date like 2024-01-31`},
+		{"héllo size:x", 11, `invalid size "x", expected a number with an optional unit like 10MB`},
+	}
+
+	for _, test := range tests {
+		_, err := Compile(test.input)
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("%q: got %v, want a parse error", test.input, err)
+			continue
+		}
+		if parseErr.Pos != test.pos || parseErr.Msg != test.msg {
+			t.Errorf("%q: got %q at %d, want %q at %d", test.input, parseErr.Msg, parseErr.Pos, test.msg, test.pos)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package query
+
+import (
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenPhrase
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenLeftParen
+	tokenRightParen
+	tokenEnd
+)
+
+type token struct {
+	kind   tokenKind
+	value  string
+	pos    int
+	quoted bool
+}
+
+func tokenize(input string) ([]token, error) {
+	var tokens []token
+	runes := []rune(input)
+	i := 0
+
+	for i < len(runes) {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenLeftParen, value: "(", pos: i})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenRightParen, value: ")", pos: i})
+			i++
+		case r == '-' && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]) && runes[i+1] != ')':
+			tokens = append(tokens, token{kind: tokenNot, value: "-", pos: i})
+			i++
+		case r == '"':
+			start := i
+			end := indexRune(runes, '"', i+1)
+			if end < 0 {
+				return nil, &ParseError{Query: input, Pos: start, Msg: "unterminated phrase"}
+			}
+			tokens = append(tokens, token{kind: tokenPhrase, value: string(runes[i+1 : end]), pos: start, quoted: true})
+			i = end + 1
+		default:
+			start := i
+			var word strings.Builder
+			quoted := false
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && runes[i] != '(' && runes[i] != ')' {
+				if runes[i] == '"' {
+					end := indexRune(runes, '"', i+1)
+					if end < 0 {
+						return nil, &ParseError{Query: input, Pos: i, Msg: "unterminated quote"}
+					}
+					word.WriteString(string(runes[i+1 : end]))
+					quoted = true
+					i = end + 1
+					continue
+				}
+				word.WriteRune(runes[i])
+				i++
+			}
+
+			value := word.String()
+			kind := tokenWord
+			if !quoted {
+				switch value {
+				case "AND":
+					kind = tokenAnd
+				case "OR":
+					kind = tokenOr
+				case "NOT":
+					kind = tokenNot
+				}
+			}
+			tokens = append(tokens, token{kind: kind, value: value, pos: start, quoted: quoted})
+		}
+	}
+
+	tokens = append(tokens, token{kind: tokenEnd, pos: len(runes)})
+
+	return tokens, nil
+}
+
+func indexRune(runes []rune, target rune, from int) int {
+	for i := from; i < len(runes); i++ {
+		if runes[i] == target {
+			return i
+		}
+	}
+
+	return -1
+}
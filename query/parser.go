@@ -0,0 +1,247 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+type ParseError struct {
+	Query string
+	Pos   int
+	Msg   string
+}
+
+func (e *ParseError) Error() string {
+	caret := strings.Repeat(" ", e.Pos) + "^"
+	return fmt.Sprintf("query error at position %d: %s\n  %s\n  %s", e.Pos+1, e.Msg, e.Query, caret)
+}
+
+type node interface{}
+
+type andNode struct {
+	left  node
+	right node
+}
+
+type orNode struct {
+	left  node
+	right node
+}
+
+type notNode struct {
+	operand node
+}
+
+type textTerm struct {
+	value  string
+	phrase bool
+	pos    int
+}
+
+type fieldTerm struct {
+	field    string
+	op       string
+	value    string
+	pos      int
+	valuePos int
+}
+
+var fields = []string{
+	"ext",
+	"size",
+	"modified",
+	"accessed",
+	"owner",
+	"group",
+	"is",
+	"path",
+	"name",
+	"tag",
+	"type",
+}
+
+type parser struct {
+	input  string
+	tokens []token
+	pos    int
+}
+
+func parse(input string) (node, error) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := parser{input: input, tokens: tokens}
+	if p.peek().kind == tokenEnd {
+		return nil, nil
+	}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if next := p.peek(); next.kind != tokenEnd {
+		if next.kind == tokenRightParen {
+			return nil, p.errorAt(next.pos, "unmatched closing parenthesis")
+		}
+		return nil, p.errorAt(next.pos, fmt.Sprintf("unexpected %q", next.value))
+	}
+
+	return root, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	current := p.tokens[p.pos]
+	if current.kind != tokenEnd {
+		p.pos++
+	}
+
+	return current
+}
+
+func (p *parser) errorAt(pos int, msg string) error {
+	return &ParseError{Query: p.input, Pos: pos, Msg: msg}
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().kind == tokenOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		switch p.peek().kind {
+		case tokenAnd:
+			p.next()
+		case tokenWord, tokenPhrase, tokenNot, tokenLeftParen:
+		default:
+			return left, nil
+		}
+
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.peek().kind == tokenNot {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand: operand}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	current := p.next()
+
+	switch current.kind {
+	case tokenLeftParen:
+		if p.peek().kind == tokenRightParen {
+			return nil, p.errorAt(p.peek().pos, "empty parentheses")
+		}
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek().kind != tokenRightParen {
+			return nil, p.errorAt(current.pos, "missing closing parenthesis")
+		}
+		p.next()
+		return inner, nil
+	case tokenPhrase:
+		if strings.TrimSpace(current.value) == "" {
+			return nil, p.errorAt(current.pos, "empty phrase")
+		}
+		return textTerm{value: current.value, phrase: true, pos: current.pos}, nil
+	case tokenWord:
+		return p.parseWord(current)
+	case tokenEnd:
+		return nil, p.errorAt(current.pos, "unexpected end of query")
+	default:
+		return nil, p.errorAt(current.pos, fmt.Sprintf("unexpected %q", current.value))
+	}
+}
+
+func (p *parser) parseWord(current token) (node, error) {
+	word := current.value
+	split := strings.IndexAny(word, ":<>=")
+	if split <= 0 {
+		return textTerm{value: word, phrase: current.quoted, pos: current.pos}, nil
+	}
+
+	field := strings.ToLower(word[:split])
+	known := false
+	for _, candidate := range fields {
+		if candidate == field {
+			known = true
+			break
+		}
+	}
+	if !known {
+		if word[split] == ':' && isIdentifier(word[:split]) {
+			return nil, p.errorAt(current.pos, fmt.Sprintf("unknown field %q", word[:split]))
+		}
+		return textTerm{value: word, phrase: current.quoted, pos: current.pos}, nil
+	}
+
+	rest := word[split:]
+	if strings.HasPrefix(rest, ":") {
+		rest = rest[1:]
+	}
+	op := ":"
+	for _, candidate := range []string{">=", "<=", ">", "<", "="} {
+		if strings.HasPrefix(rest, candidate) {
+			op = candidate
+			rest = rest[len(candidate):]
+			break
+		}
+	}
+
+	valuePos := current.pos + len([]rune(word)) - len([]rune(rest))
+	if rest == "" {
+		return nil, p.errorAt(valuePos, fmt.Sprintf("missing value for %s", field))
+	}
+
+	return fieldTerm{field: field, op: op, value: rest, pos: current.pos, valuePos: valuePos}, nil
+}
+
+func isIdentifier(value string) bool {
+	for _, r := range value {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && r != '_' {
+			return false
+		}
+	}
+
+	return true
+}
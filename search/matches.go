@@ -0,0 +1,155 @@
+package search
+
+import (
+	"database/sql"
+	"icu/data"
+	"icu/query"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	matchesPerResult = 5
+	excerptWidth     = 120
+)
+
+func AttachMatches(con *sql.DB, input string, results []data.SearchResult) error {
+	expanded, err := expandSavedSearches(con, input)
+	if err != nil {
+		return err
+	}
+	compiled, err := query.Compile(expanded)
+	if err != nil {
+		return err
+	}
+	if len(compiled.TextTerms) == 0 {
+		return nil
+	}
+
+	for i := range results {
+		fullText, err := data.GetFullText(con, results[i].EntryID)
+		if err != nil {
+			return err
+		}
+		results[i].Matches = locateMatches(fullText, compiled.TextTerms, matchesPerResult)
+	}
+
+	return nil
+}
+
+func locateMatches(text string, terms []query.TextTerm, limit int) []data.ContentMatch {
+	var matches []data.ContentMatch
+	if text == "" {
+		return matches
+	}
+
+	needles := make([][]rune, 0, len(terms))
+	for _, term := range terms {
+		needles = append(needles, lowerRunes(term.Value))
+	}
+
+	for lineIndex, line := range strings.Split(text, "\n") {
+		lineRunes := []rune(line)
+		haystack := lowerRunes(line)
+
+		var highlights [][2]int
+		for i, needle := range needles {
+			for from := 0; ; {
+				at := indexRunes(haystack, needle, from)
+				if at < 0 {
+					break
+				}
+				if terms[i].Phrase || at == 0 || !isWordRune(haystack[at-1]) {
+					highlights = append(highlights, [2]int{at, at + len(needle)})
+				}
+				from = at + len(needle)
+			}
+		}
+		if len(highlights) == 0 {
+			continue
+		}
+
+		first := highlights[0]
+		for _, highlight := range highlights {
+			if highlight[0] < first[0] {
+				first = highlight
+			}
+		}
+
+		excerpt, offset := excerptAround(lineRunes, first[0])
+		var shifted [][2]int
+		for _, highlight := range highlights {
+			start, end := highlight[0]-offset, highlight[1]-offset
+			if start < 0 || end > len([]rune(excerpt)) {
+				continue
+			}
+			shifted = append(shifted, [2]int{start, end})
+		}
+
+		sort.Slice(shifted, func(i, j int) bool { return shifted[i][0] < shifted[j][0] })
+
+		matches = append(matches, data.ContentMatch{
+			Line:       lineIndex + 1,
+			Column:     first[0] + 1,
+			Excerpt:    excerpt,
+			Highlights: shifted,
+		})
+		if len(matches) >= limit {
+			break
+		}
+	}
+
+	return matches
+}
+
+func excerptAround(line []rune, at int) (string, int) {
+	if len(line) <= excerptWidth {
+		return string(line), 0
+	}
+
+	start := at - excerptWidth/4
+	if start < 0 {
+		start = 0
+	}
+	end := start + excerptWidth
+	if end > len(line) {
+		end = len(line)
+		start = end - excerptWidth
+	}
+
+	return string(line[start:end]), start
+}
+
+func lowerRunes(value string) []rune {
+	runes := []rune(value)
+	for i, r := range runes {
+		runes[i] = unicode.ToLower(r)
+	}
+
+	return runes
+}
+
+func indexRunes(haystack, needle []rune, from int) int {
+	if len(needle) == 0 {
+		return -1
+	}
+	for i := from; i+len(needle) <= len(haystack); i++ {
+		found := true
+		for j := range needle {
+			if haystack[i+j] != needle[j] {
+				found = false
+				break
+			}
+		}
+		if found {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,121 @@
+package search
+
+import (
+	"fmt"
+	"icu/config"
+	"icu/data"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	SortRelevance = "relevance"
+	SortMtime     = "mtime"
+	SortAtime     = "atime"
+	SortSize      = "size"
+	SortPath      = "path"
+
+	candidateFactor = 10
+	maxCandidates   = 2000
+)
+
+var SortOrders = []string{SortRelevance, SortMtime, SortAtime, SortSize, SortPath}
+
+type Options struct {
+	Sort    string
+	Limit   int
+	Ranking config.Ranking
+}
+
+func orderByClause(sortOrder string, hasRank bool) (string, error) {
+	switch sortOrder {
+	case "", SortRelevance:
+		if hasRank {
+			return "rank, e.path", nil
+		}
+		return "julianday(e.modification_time) desc, e.path", nil
+	case SortMtime:
+		return "julianday(e.modification_time) desc, e.path", nil
+	case SortAtime:
+		return "julianday(e.access_time) desc, e.path", nil
+	case SortSize:
+		return "e.size desc, e.path", nil
+	case SortPath:
+		return "e.path", nil
+	}
+
+	return "", fmt.Errorf("unknown sort order %q, expected one of %s", sortOrder, strings.Join(SortOrders, ", "))
+}
+
+func candidateLimit(options Options) int {
+	if options.Sort != "" && options.Sort != SortRelevance {
+		return options.Limit
+	}
+
+	return min(options.Limit*candidateFactor, max(maxCandidates, options.Limit))
+}
+
+func recency(moment time.Time, now time.Time, halfLifeDays float64) float64 {
+	if halfLifeDays <= 0 || moment.IsZero() {
+		return 0
+	}
+	ageDays := now.Sub(moment).Hours() / 24
+	if ageDays < 0 {
+		ageDays = 0
+	}
+
+	return math.Exp(-math.Ln2 * ageDays / halfLifeDays)
+}
+
+// blendRanking re-orders candidates by a weighted sum of text relevance, modification and access
+// recency and directory depth. Each signal is scaled to 0..1 so the weights are comparable.
+func blendRanking(candidates []data.SearchResult, weights config.Ranking, limit int) []data.SearchResult {
+	if len(candidates) == 0 {
+		return candidates
+	}
+
+	bestRank := 0.0
+	minDepth := math.MaxInt
+	depths := make([]int, len(candidates))
+	for i, candidate := range candidates {
+		bestRank = math.Min(bestRank, candidate.Rank)
+		depths[i] = strings.Count(candidate.Path, "/")
+		minDepth = min(minDepth, depths[i])
+	}
+
+	now := time.Now()
+	scores := make([]float64, len(candidates))
+	for i, candidate := range candidates {
+		relevance := 1.0
+		if bestRank < 0 {
+			relevance = candidate.Rank / bestRank
+		}
+		depth := 1 / float64(1+depths[i]-minDepth)
+
+		scores[i] = weights.TextWeight*relevance +
+			weights.ModifiedWeight*recency(candidate.ModificationTime, now, weights.ModifiedHalfLifeDays) +
+			weights.AccessedWeight*recency(candidate.AccessTime, now, weights.AccessedHalfLifeDays) +
+			weights.DepthWeight*depth
+	}
+
+	order := make([]int, len(candidates))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return scores[order[a]] > scores[order[b]]
+	})
+
+	if len(order) > limit {
+		order = order[:limit]
+	}
+	ranked := make([]data.SearchResult, len(order))
+	for i, index := range order {
+		ranked[i] = candidates[index]
+		ranked[i].Rank = -scores[index]
+	}
+
+	return ranked
+}
@@ -0,0 +1,141 @@
+package search
+
+import (
+	"database/sql"
+	"fmt"
+	"icu/data"
+	"icu/db"
+	"icu/query"
+	"regexp"
+	"strings"
+)
+
+const maxSavedSearchDepth = 5
+
+var (
+	savedReferencePattern = regexp.MustCompile(`(^|[\s(])@([\w.-]+)`)
+	savedNamePattern      = regexp.MustCompile(`^[\w.-]+$`)
+)
+
+// expandSavedSearches replaces every @name in the input with the saved query of that name, wrapped
+// in parentheses so it combines with the rest of the input like any other group.
+func expandSavedSearches(con *sql.DB, input string) (string, error) {
+	for depth := 0; ; depth++ {
+		references := savedReferences(input)
+		if len(references) == 0 {
+			return input, nil
+		}
+		if depth == maxSavedSearchDepth {
+			return "", fmt.Errorf("saved searches are nested more than %d levels deep", maxSavedSearchDepth)
+		}
+
+		var expanded strings.Builder
+		last := 0
+		for _, reference := range references {
+			nameStart, nameEnd := reference[4], reference[5]
+			savedSearch, err := data.GetSavedSearch(con, input[nameStart:nameEnd])
+			if err != nil {
+				return "", err
+			}
+			expanded.WriteString(input[last : nameStart-1])
+			expanded.WriteString("(" + savedSearch.Query + ")")
+			last = nameEnd
+		}
+		expanded.WriteString(input[last:])
+		input = expanded.String()
+	}
+}
+
+// savedReferences returns the submatch indexes of every @name outside of quotes, so that a phrase
+// such as "mail me @home" is searched for as written.
+func savedReferences(input string) [][]int {
+	var references [][]int
+	for _, match := range savedReferencePattern.FindAllStringSubmatchIndex(input, -1) {
+		if strings.Count(input[:match[4]], `"`)%2 == 0 {
+			references = append(references, match)
+		}
+	}
+
+	return references
+}
+
+func SavedMain(arguments []string) error {
+	usage := fmt.Errorf("usage: saved [list] | saved add <name> <query> | saved edit <name> <query> | saved delete <name>")
+
+	dbPath, err := db.GetDBPath()
+	if err != nil {
+		return err
+	}
+	con, err := db.CreateConnection(dbPath)
+	if err != nil {
+		return err
+	}
+	defer func(con *sql.DB) {
+		err = db.CloseConnection(con)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}(con)
+
+	if len(arguments) == 0 || arguments[0] == "list" {
+		savedSearches, err := data.GetSavedSearches(con)
+		if err != nil {
+			return err
+		}
+		if len(savedSearches) == 0 {
+			fmt.Println("no saved searches")
+		}
+		for _, savedSearch := range savedSearches {
+			fmt.Printf("@%s\t%s\n", savedSearch.Name, savedSearch.Query)
+		}
+		return nil
+	}
+
+	switch arguments[0] {
+	case "add", "edit":
+		if len(arguments) < 3 {
+			return usage
+		}
+		name := strings.TrimPrefix(arguments[1], "@")
+		if !savedNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid name %q, use letters, digits, '.', '-' and '_'", name)
+		}
+		searchQuery := strings.Join(arguments[2:], " ")
+
+		expanded, err := expandSavedSearches(con, searchQuery)
+		if err != nil {
+			return err
+		}
+		_, err = query.Compile(expanded)
+		if err != nil {
+			return err
+		}
+
+		if arguments[0] == "add" {
+			if _, err := data.GetSavedSearch(con, name); err == nil {
+				return fmt.Errorf("a saved search named @%s already exists, use saved edit to change it", name)
+			}
+			err = data.WriteSavedSearch(con, name, searchQuery)
+		} else {
+			err = data.UpdateSavedSearch(con, name, searchQuery)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("saved @%s\n", name)
+	case "delete":
+		if len(arguments) != 2 {
+			return usage
+		}
+		name := strings.TrimPrefix(arguments[1], "@")
+		err = data.DeleteSavedSearch(con, name)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("deleted @%s\n", name)
+	default:
+		return usage
+	}
+
+	return nil
+}
@@ -0,0 +1,30 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSavedReferences(t *testing.T) {
+	tests := []struct {
+		input string
+		names []string
+	}{
+		{"@gofiles", []string{"gofiles"}},
+		{"@gofiles size>1MB (@recent OR @old.logs)", []string{"gofiles", "recent", "old.logs"}},
+		{`"mail me @home"`, nil},
+		{`"mail me @home" @work`, []string{"work"}},
+		{`name:"@draft" @notes`, []string{"notes"}},
+		{"user@example.com", nil},
+	}
+
+	for _, test := range tests {
+		var names []string
+		for _, reference := range savedReferences(test.input) {
+			names = append(names, test.input[reference[4]:reference[5]])
+		}
+		if !reflect.DeepEqual(names, test.names) {
+			t.Errorf("%q: got %v, want %v", test.input, names, test.names)
+		}
+	}
+}
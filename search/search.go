@@ -0,0 +1,128 @@
+package search
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"icu/config"
+	"icu/data"
+	"icu/db"
+	"icu/query"
+	"icu/utils"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+const resultLimit = 50
+
+var highlightStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("9"))
+
+func Find(con *sql.DB, input string, options Options) ([]data.SearchResult, error) {
+	if strings.TrimSpace(input) == "" {
+		return nil, nil
+	}
+
+	expanded, err := expandSavedSearches(con, input)
+	if err != nil {
+		return nil, err
+	}
+	compiled, err := query.Compile(expanded)
+	if err != nil {
+		return nil, err
+	}
+
+	orderBy, err := orderByClause(options.Sort, compiled.RankMatch != "")
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := data.QueryEntries(con, compiled.Where, compiled.Args, compiled.RankMatch, orderBy, candidateLimit(options))
+	if err != nil {
+		return nil, err
+	}
+	if options.Sort != "" && options.Sort != SortRelevance {
+		return results, nil
+	}
+
+	return blendRanking(results, options.Ranking, options.Limit), nil
+}
+
+func Main(arguments []string) error {
+	flags := flag.NewFlagSet("search", flag.ContinueOnError)
+	flags.SetOutput(os.Stdout)
+	sortOrder := flags.String("sort", SortRelevance, "result order: "+strings.Join(SortOrders, ", "))
+	err := flags.Parse(arguments)
+	if err != nil {
+		return err
+	}
+
+	input := strings.Join(flags.Args(), " ")
+	if strings.TrimSpace(input) == "" {
+		return fmt.Errorf("usage: search [--sort relevance|mtime|atime|size|path] <query>")
+	}
+
+	loadedConfig, err := config.Load()
+	if err != nil {
+		return err
+	}
+	options := Options{Sort: *sortOrder, Limit: resultLimit, Ranking: loadedConfig.Ranking}
+
+	dbPath, err := db.GetDBPath()
+	if err != nil {
+		return err
+	}
+	con, err := db.CreateConnection(dbPath)
+	if err != nil {
+		return err
+	}
+	defer func(con *sql.DB) {
+		err = db.CloseConnection(con)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}(con)
+
+	results, err := Find(con, input, options)
+	if err != nil {
+		return err
+	}
+	err = AttachMatches(con, input, results)
+	if err != nil {
+		return err
+	}
+
+	if len(results) == 0 {
+		fmt.Println("no matches")
+		return nil
+	}
+	for _, result := range results {
+		if len(result.Matches) == 0 {
+			fmt.Printf("%s\t%s\t%s\n", result.Path, utils.FormatSize(result.Size), result.ModificationTime.Format("2006-01-02 15:04"))
+			continue
+		}
+		for _, match := range result.Matches {
+			fmt.Printf("%s:%d:%d: %s\n", result.Path, match.Line, match.Column, highlightExcerpt(match))
+		}
+	}
+
+	return nil
+}
+
+func highlightExcerpt(match data.ContentMatch) string {
+	excerpt := []rune(match.Excerpt)
+	var highlighted strings.Builder
+	position := 0
+	for _, highlight := range match.Highlights {
+		if highlight[0] < position {
+			continue
+		}
+		highlighted.WriteString(string(excerpt[position:highlight[0]]))
+		highlighted.WriteString(highlightStyle.Render(string(excerpt[highlight[0]:highlight[1]])))
+		position = highlight[1]
+	}
+	highlighted.WriteString(string(excerpt[position:]))
+
+	return highlighted.String()
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"icu/config"
 	"icu/db"
 )
 
@@ -29,12 +30,29 @@ func Main() error {
 		fmt.Println("servicePath does not exist, creating it: ")
 		os.MkdirAll(servicePath, os.ModePerm)
 		fmt.Println("initializing database")
-		db.InitializeDB(servicePath)
+		err = db.InitializeDB(servicePath)
+		if err != nil {
+			return err
+		}
+		err = config.WriteDefault(servicePath)
+		if err != nil {
+			return err
+		}
 		fmt.Println("setup complete")
 	} else if err != nil {
 		return fmt.Errorf("error checking if service path exist: %w", err)
 	} else if !info.IsDir() {
 		return fmt.Errorf("conflicting service path was found. path exist but is not a directory%v", info.Name())
+	} else {
+		fmt.Println("servicePath exists, updating database tables")
+		err = db.InitializeDB(servicePath)
+		if err != nil {
+			return err
+		}
+		err = config.WriteDefault(servicePath)
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -0,0 +1,101 @@
+package similar
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"icu/data"
+	"math"
+	"strings"
+	"unicode"
+)
+
+const (
+	// signatureLength is the number of hash functions, the similarity estimate is accurate to
+	// roughly 1/sqrt(signatureLength)
+	signatureLength = 64
+	shingleSize     = 3
+	// documents with fewer words than this give too few shingles for a meaningful estimate
+	minWords = 16
+)
+
+var seeds = func() [signatureLength]uint64 {
+	var generated [signatureLength]uint64
+	state := uint64(0x9e3779b97f4a7c15)
+	for i := range generated {
+		state = splitMix(state)
+		generated[i] = state
+	}
+	return generated
+}()
+
+func splitMix(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// Collect attaches a MinHash signature of the extracted text to entry.
+func Collect(entry *data.EntryCollection) {
+	if len(entry.FullTextIndex) == 0 {
+		return
+	}
+
+	entry.MinHash = Signature(string(entry.FullTextIndex))
+}
+
+// Signature computes the MinHash of the word shingles of text. The share of equal positions in
+// two signatures estimates the Jaccard similarity of their shingle sets. It returns nil for texts
+// that are too short.
+func Signature(text string) []byte {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) < minWords {
+		return nil
+	}
+
+	var minimums [signatureLength]uint32
+	for i := range minimums {
+		minimums[i] = math.MaxUint32
+	}
+
+	hash := fnv.New64a()
+	for i := 0; i+shingleSize <= len(words); i++ {
+		hash.Reset()
+		for _, word := range words[i : i+shingleSize] {
+			hash.Write([]byte(word))
+			hash.Write([]byte{0})
+		}
+		shingle := hash.Sum64()
+		for j, seed := range seeds {
+			value := uint32(splitMix(shingle^seed) >> 32)
+			if value < minimums[j] {
+				minimums[j] = value
+			}
+		}
+	}
+
+	signature := make([]byte, signatureLength*4)
+	for i, minimum := range minimums {
+		binary.LittleEndian.PutUint32(signature[i*4:], minimum)
+	}
+
+	return signature
+}
+
+// Similarity estimates the Jaccard similarity of the documents behind two signatures.
+func Similarity(a []byte, b []byte) float64 {
+	if len(a) != signatureLength*4 || len(b) != signatureLength*4 {
+		return 0
+	}
+
+	equal := 0
+	for i := 0; i < len(a); i += 4 {
+		if binary.LittleEndian.Uint32(a[i:]) == binary.LittleEndian.Uint32(b[i:]) {
+			equal++
+		}
+	}
+
+	return float64(equal) / signatureLength
+}
@@ -0,0 +1,320 @@
+package similar
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"icu/data"
+	"icu/db"
+	"icu/utils"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	// bands and rows split a signature for locality-sensitive hashing. Documents agreeing on all
+	// rows of any band become candidates, which catches pairs above roughly 0.5 similarity.
+	bands = 16
+	rows  = signatureLength / bands
+)
+
+var groupStyle = lipgloss.NewStyle().Bold(true)
+
+type Match struct {
+	Path       string
+	Similarity float64
+}
+
+type Cluster struct {
+	Members []Match // similarity of every member to the first one
+}
+
+func openConnection() (*sql.DB, error) {
+	dbPath, err := db.GetDBPath()
+	if err != nil {
+		return nil, err
+	}
+
+	return db.CreateConnection(dbPath)
+}
+
+func closeConnection(con *sql.DB) {
+	err := db.CloseConnection(con)
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+func resolvePath(rawPath string) (string, error) {
+	path, err := utils.ExpandPath(rawPath)
+	if err != nil {
+		return "", err
+	}
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("could not resolve %s: %w", rawPath, err)
+	}
+
+	return path, nil
+}
+
+// Similar compares the document at path with every other fingerprinted document.
+func Similar(fingerprints []data.Fingerprint, path string, threshold float64) ([]Match, error) {
+	var target []byte
+	var targetFile data.FileID
+	for _, fingerprint := range fingerprints {
+		if fingerprint.Path == path {
+			target = fingerprint.MinHash
+			targetFile = fingerprint.File
+			break
+		}
+	}
+	if target == nil {
+		return nil, fmt.Errorf("%s has no fingerprint, only indexed documents with enough text have one", path)
+	}
+
+	var matches []Match
+	for _, fingerprint := range fingerprints {
+		// hard links of the document are the document itself
+		if fingerprint.Path == path || fingerprint.File == targetFile {
+			continue
+		}
+		similarity := Similarity(target, fingerprint.MinHash)
+		if similarity >= threshold {
+			matches = append(matches, Match{Path: fingerprint.Path, Similarity: similarity})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Similarity != matches[j].Similarity {
+			return matches[i].Similarity > matches[j].Similarity
+		}
+		return matches[i].Path < matches[j].Path
+	})
+
+	return matches, nil
+}
+
+// NearDuplicates clusters documents whose estimated similarity reaches threshold. Candidate pairs
+// come from banding the signatures, so not every pair of documents has to be compared. Clusters are
+// merged from the most similar pair down, and only when every document of one is similar enough to
+// every document of the other, so no two members of a cluster fall below threshold.
+func NearDuplicates(fingerprints []data.Fingerprint, threshold float64) []Cluster {
+	buckets := make(map[string][]int)
+	for i, fingerprint := range fingerprints {
+		if len(fingerprint.MinHash) != signatureLength*4 {
+			continue
+		}
+		for band := 0; band < bands; band++ {
+			key := fmt.Sprintf("%d:%s", band, fingerprint.MinHash[band*rows*4:(band+1)*rows*4])
+			buckets[key] = append(buckets[key], i)
+		}
+	}
+
+	type candidate struct {
+		a, b       int
+		similarity float64
+	}
+	var candidates []candidate
+	compared := make(map[[2]int]bool)
+	for _, bucket := range buckets {
+		for a := 0; a < len(bucket); a++ {
+			for b := a + 1; b < len(bucket); b++ {
+				pair := [2]int{bucket[a], bucket[b]}
+				if compared[pair] {
+					continue
+				}
+				compared[pair] = true
+				if fingerprints[pair[0]].File == fingerprints[pair[1]].File {
+					continue
+				}
+				similarity := Similarity(fingerprints[pair[0]].MinHash, fingerprints[pair[1]].MinHash)
+				if similarity >= threshold {
+					candidates = append(candidates, candidate{a: pair[0], b: pair[1], similarity: similarity})
+				}
+			}
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].similarity != candidates[j].similarity {
+			return candidates[i].similarity > candidates[j].similarity
+		}
+		if candidates[i].a != candidates[j].a {
+			return candidates[i].a < candidates[j].a
+		}
+		return candidates[i].b < candidates[j].b
+	})
+
+	parents := make([]int, len(fingerprints))
+	members := make([][]int, len(fingerprints))
+	for i := range parents {
+		parents[i] = i
+		members[i] = []int{i}
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parents[i] != i {
+			parents[i] = find(parents[i])
+		}
+		return parents[i]
+	}
+
+	for _, pair := range candidates {
+		a, b := find(pair.a), find(pair.b)
+		if a == b || !allSimilar(fingerprints, members[a], members[b], threshold) {
+			continue
+		}
+		parents[a] = b
+		members[b] = append(members[b], members[a]...)
+		members[a] = nil
+	}
+
+	grouped := make(map[int][]int)
+	for i := range fingerprints {
+		root := find(i)
+		grouped[root] = append(grouped[root], i)
+	}
+
+	var clusters []Cluster
+	for _, members := range grouped {
+		if len(members) < 2 {
+			continue
+		}
+		// fingerprints are sorted by path, so the first member is the alphabetically first one
+		first := fingerprints[members[0]]
+		cluster := Cluster{}
+		for _, member := range members {
+			cluster.Members = append(cluster.Members, Match{
+				Path:       fingerprints[member].Path,
+				Similarity: Similarity(first.MinHash, fingerprints[member].MinHash),
+			})
+		}
+		clusters = append(clusters, cluster)
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		if len(clusters[i].Members) != len(clusters[j].Members) {
+			return len(clusters[i].Members) > len(clusters[j].Members)
+		}
+		return clusters[i].Members[0].Path < clusters[j].Members[0].Path
+	})
+
+	return clusters
+}
+
+// allSimilar reports whether every document of one cluster reaches threshold with every document of
+// the other.
+func allSimilar(fingerprints []data.Fingerprint, left, right []int, threshold float64) bool {
+	for _, a := range left {
+		for _, b := range right {
+			if Similarity(fingerprints[a].MinHash, fingerprints[b].MinHash) < threshold {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func validateThreshold(threshold float64) error {
+	if threshold <= 0 || threshold > 1 {
+		return fmt.Errorf("threshold must be between 0 and 1, got %g", threshold)
+	}
+
+	return nil
+}
+
+func SimilarMain(arguments []string) error {
+	flags := flag.NewFlagSet("similar", flag.ContinueOnError)
+	flags.SetOutput(os.Stdout)
+	threshold := flags.Float64("threshold", 0.5, "minimum estimated similarity between 0 and 1")
+	err := flags.Parse(arguments)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: similar [-threshold 0.5] <path>")
+	}
+	err = validateThreshold(*threshold)
+	if err != nil {
+		return err
+	}
+	path, err := resolvePath(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	con, err := openConnection()
+	if err != nil {
+		return err
+	}
+	defer closeConnection(con)
+
+	fingerprints, err := data.GetFingerprints(con, "")
+	if err != nil {
+		return err
+	}
+	matches, err := Similar(fingerprints, path, *threshold)
+	if err != nil {
+		return err
+	}
+	if len(matches) == 0 {
+		fmt.Println("no similar documents")
+	}
+	for _, match := range matches {
+		fmt.Printf("%.2f  %s\n", match.Similarity, match.Path)
+	}
+
+	return nil
+}
+
+func NearDupesMain(arguments []string) error {
+	flags := flag.NewFlagSet("near-dupes", flag.ContinueOnError)
+	flags.SetOutput(os.Stdout)
+	threshold := flags.Float64("threshold", 0.9, "minimum estimated similarity between 0 and 1")
+	pathPrefix := flags.String("path", "", "only consider documents below this path")
+	err := flags.Parse(arguments)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return fmt.Errorf("usage: near-dupes [-threshold 0.9] [-path dir]")
+	}
+	err = validateThreshold(*threshold)
+	if err != nil {
+		return err
+	}
+	scope := ""
+	if *pathPrefix != "" {
+		scope, err = resolvePath(*pathPrefix)
+		if err != nil {
+			return err
+		}
+	}
+
+	con, err := openConnection()
+	if err != nil {
+		return err
+	}
+	defer closeConnection(con)
+
+	fingerprints, err := data.GetFingerprints(con, scope)
+	if err != nil {
+		return err
+	}
+	clusters := NearDuplicates(fingerprints, *threshold)
+	if len(clusters) == 0 {
+		fmt.Println("no near-duplicates found")
+		return nil
+	}
+	for _, cluster := range clusters {
+		fmt.Println(groupStyle.Render(fmt.Sprintf("%d similar documents", len(cluster.Members))))
+		for _, member := range cluster.Members {
+			fmt.Printf("  %.2f  %s\n", member.Similarity, member.Path)
+		}
+	}
+	fmt.Printf("%d clusters\n", len(clusters))
+
+	return nil
+}
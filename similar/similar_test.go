@@ -0,0 +1,67 @@
+package similar
+
+import (
+	"encoding/binary"
+	"icu/data"
+	"testing"
+)
+
+// signature builds a MinHash signature that is 0 everywhere except at the given positions, where it
+// holds value.
+func signature(value uint32, positions ...int) []byte {
+	hashes := make([]byte, signatureLength*4)
+	for _, position := range positions {
+		binary.LittleEndian.PutUint32(hashes[position*4:], value)
+	}
+
+	return hashes
+}
+
+func span(from, to int) []int {
+	var positions []int
+	for i := from; i < to; i++ {
+		positions = append(positions, i)
+	}
+
+	return positions
+}
+
+func TestNearDuplicatesDoesNotChain(t *testing.T) {
+	// a and b differ in 8 of 64 hashes, b and c in 8 others, so a and c differ in 16
+	fingerprints := []data.Fingerprint{
+		{File: data.FileID{Inode: 1}, Path: "/a", MinHash: signature(0)},
+		{File: data.FileID{Inode: 2}, Path: "/b", MinHash: signature(1, span(0, 8)...)},
+		{File: data.FileID{Inode: 3}, Path: "/c", MinHash: signature(1, span(0, 16)...)},
+	}
+	if got := Similarity(fingerprints[0].MinHash, fingerprints[2].MinHash); got != 0.75 {
+		t.Fatalf("fixture similarity of a and c is %v, want 0.75", got)
+	}
+
+	clusters := NearDuplicates(fingerprints, 0.85)
+	if len(clusters) != 1 {
+		t.Fatalf("got %d clusters, want 1: %v", len(clusters), clusters)
+	}
+	members := clusters[0].Members
+	if len(members) != 2 || members[0].Path != "/a" || members[1].Path != "/b" {
+		t.Errorf("got members %v, want /a and /b", members)
+	}
+
+	// below the similarity of a and c chaining is harmless, every pair reaches the threshold
+	clusters = NearDuplicates(fingerprints, 0.7)
+	if len(clusters) != 1 || len(clusters[0].Members) != 3 {
+		t.Errorf("got %v, want a single cluster of three", clusters)
+	}
+}
+
+func TestValidateThreshold(t *testing.T) {
+	for _, threshold := range []float64{0.01, 0.5, 1} {
+		if err := validateThreshold(threshold); err != nil {
+			t.Errorf("%v: %v", threshold, err)
+		}
+	}
+	for _, threshold := range []float64{0, -0.5, 1.01} {
+		if err := validateThreshold(threshold); err == nil {
+			t.Errorf("%v: want an error", threshold)
+		}
+	}
+}
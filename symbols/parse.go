@@ -0,0 +1,91 @@
+package symbols
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"icu/data"
+	"strings"
+)
+
+const (
+	KindFunc   = "func"
+	KindMethod = "method"
+	KindType   = "type"
+	KindConst  = "const"
+	KindVar    = "var"
+)
+
+var Kinds = []string{KindFunc, KindMethod, KindType, KindConst, KindVar}
+
+// Collect parses the extracted text of a Go source and attaches its declarations to entry. Files
+// with syntax errors still yield whatever was declared before the first error.
+func Collect(entry *data.EntryCollection) {
+	if entry.Extension != ".go" || len(entry.FullTextIndex) == 0 {
+		return
+	}
+
+	entry.Symbols = Parse(entry.FullPath, entry.FullTextIndex)
+}
+
+func Parse(filename string, source []byte) []data.Symbol {
+	fileSet := token.NewFileSet()
+	file, _ := parser.ParseFile(fileSet, filename, source, parser.ParseComments|parser.SkipObjectResolution)
+	if file == nil {
+		return nil
+	}
+
+	packageName := file.Name.Name
+	var symbols []data.Symbol
+	add := func(name *ast.Ident, kind string, receiver string, doc *ast.CommentGroup) {
+		if name == nil || name.Name == "_" {
+			return
+		}
+		symbols = append(symbols, data.Symbol{
+			Name:     name.Name,
+			Kind:     kind,
+			Package:  packageName,
+			Receiver: receiver,
+			Line:     fileSet.Position(name.Pos()).Line,
+			Doc:      strings.TrimSpace(doc.Text()),
+		})
+	}
+
+	for _, declaration := range file.Decls {
+		switch decl := declaration.(type) {
+		case *ast.FuncDecl:
+			if decl.Recv != nil && len(decl.Recv.List) > 0 {
+				add(decl.Name, KindMethod, types.ExprString(decl.Recv.List[0].Type), decl.Doc)
+				continue
+			}
+			add(decl.Name, KindFunc, "", decl.Doc)
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				// a lone spec carries its comment on the declaration, grouped specs on themselves
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					add(s.Name, KindType, "", specDoc(s.Doc, decl))
+				case *ast.ValueSpec:
+					kind := KindVar
+					if decl.Tok == token.CONST {
+						kind = KindConst
+					}
+					for _, name := range s.Names {
+						add(name, kind, "", specDoc(s.Doc, decl))
+					}
+				}
+			}
+		}
+	}
+
+	return symbols
+}
+
+func specDoc(doc *ast.CommentGroup, decl *ast.GenDecl) *ast.CommentGroup {
+	if doc == nil && !decl.Lparen.IsValid() {
+		return decl.Doc
+	}
+
+	return doc
+}
@@ -0,0 +1,139 @@
+package symbols
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"icu/data"
+	"icu/db"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+const symbolLimit = 200
+
+var (
+	kindStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("6"))
+	docStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+)
+
+// Find looks up declarations by name. Exact matches, ignoring case, come first, then prefix and
+// substring matches. A pattern containing *, ? or [ is matched as a glob instead, and a qualified
+// pattern like http.Get or Server.Start restricts the package or receiver.
+func Find(con *sql.DB, pattern string, kind string, limit int) ([]data.Symbol, error) {
+	var conditions []string
+	var args []any
+
+	if kind != "" {
+		conditions = append(conditions, "s.kind = ?")
+		args = append(args, kind)
+	}
+
+	name := pattern
+	if dot := strings.LastIndex(pattern, "."); dot > 0 && dot < len(pattern)-1 {
+		qualifier := pattern[:dot]
+		name = pattern[dot+1:]
+		conditions = append(conditions, "(s.package = ? or ltrim(s.receiver, '*') = ? or ltrim(s.receiver, '*') like ?)")
+		args = append(args, qualifier, qualifier, qualifier+"[%")
+	}
+
+	orderBy := "length(s.name), e.path, s.line"
+	var orderArgs []any
+	if strings.ContainsAny(name, "*?[") {
+		conditions = append(conditions, "s.name glob ?")
+		args = append(args, name)
+	} else {
+		escaped := escapeLike(name)
+		conditions = append(conditions, `s.name like ? escape '\'`)
+		args = append(args, "%"+escaped+"%")
+		orderBy = `case when s.name = ? collate nocase then 0 when s.name like ? escape '\' then 1 else 2 end, ` + orderBy
+		orderArgs = append(orderArgs, name, escaped+"%")
+	}
+
+	where := "1 = 1"
+	if len(conditions) > 0 {
+		where = strings.Join(conditions, " and ")
+	}
+	// the order by placeholders follow the where placeholders in the statement
+	args = append(args, orderArgs...)
+
+	return data.QuerySymbols(con, where, args, orderBy, limit)
+}
+
+func escapeLike(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, "%", `\%`)
+
+	return strings.ReplaceAll(value, "_", `\_`)
+}
+
+// Describe renders a symbol the way it is declared, e.g. "func (*Server) Start".
+func Describe(symbol data.Symbol) string {
+	switch symbol.Kind {
+	case KindMethod:
+		return fmt.Sprintf("func (%s) %s", symbol.Receiver, symbol.Name)
+	case KindFunc:
+		return fmt.Sprintf("func %s.%s", symbol.Package, symbol.Name)
+	default:
+		return fmt.Sprintf("%s %s.%s", symbol.Kind, symbol.Package, symbol.Name)
+	}
+}
+
+// FirstLine returns the summary sentence of a doc comment.
+func FirstLine(doc string) string {
+	line, _, _ := strings.Cut(doc, "\n")
+	return line
+}
+
+func Main(arguments []string) error {
+	flags := flag.NewFlagSet("sym", flag.ContinueOnError)
+	flags.SetOutput(os.Stdout)
+	kind := flags.String("kind", "", "only list symbols of this kind: "+strings.Join(Kinds, ", "))
+	err := flags.Parse(arguments)
+	if err != nil {
+		return err
+	}
+
+	pattern := strings.Join(flags.Args(), " ")
+	if pattern == "" {
+		return fmt.Errorf("usage: sym [-kind func|method|type|const|var] <name or pattern>")
+	}
+	if *kind != "" && !slices.Contains(Kinds, *kind) {
+		return fmt.Errorf("unknown kind %q, expected one of %s", *kind, strings.Join(Kinds, ", "))
+	}
+
+	dbPath, err := db.GetDBPath()
+	if err != nil {
+		return err
+	}
+	con, err := db.CreateConnection(dbPath)
+	if err != nil {
+		return err
+	}
+	defer func(con *sql.DB) {
+		err = db.CloseConnection(con)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}(con)
+
+	found, err := Find(con, pattern, *kind, symbolLimit)
+	if err != nil {
+		return err
+	}
+	if len(found) == 0 {
+		fmt.Println("no symbols found")
+	}
+	for _, symbol := range found {
+		line := fmt.Sprintf("%s:%d: %s", symbol.Path, symbol.Line, kindStyle.Render(Describe(symbol)))
+		if doc := FirstLine(symbol.Doc); doc != "" {
+			line += "  " + docStyle.Render(doc)
+		}
+		fmt.Println(line)
+	}
+
+	return nil
+}
@@ -0,0 +1,219 @@
+package tagging
+
+import (
+	"database/sql"
+	"fmt"
+	"icu/config"
+	"icu/data"
+	"icu/db"
+	"icu/search"
+	"icu/utils"
+	"path/filepath"
+	"strings"
+)
+
+const taggedLimit = 10000
+
+func openConnection() (*sql.DB, error) {
+	dbPath, err := db.GetDBPath()
+	if err != nil {
+		return nil, err
+	}
+
+	return db.CreateConnection(dbPath)
+}
+
+func closeConnection(con *sql.DB) {
+	err := db.CloseConnection(con)
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+func resolveEntry(con *sql.DB, rawPath string) (string, data.FileID, error) {
+	path, err := utils.ExpandPath(rawPath)
+	if err != nil {
+		return "", data.FileID{}, err
+	}
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return "", data.FileID{}, fmt.Errorf("could not resolve %s: %w", rawPath, err)
+	}
+
+	file, err := data.GetFileByPath(con, path)
+	if err != nil {
+		return "", data.FileID{}, err
+	}
+
+	return path, file, nil
+}
+
+func normalizeTags(rawTags []string) ([]string, error) {
+	var tags []string
+	for _, rawTag := range rawTags {
+		for _, part := range strings.Split(rawTag, ",") {
+			if strings.TrimSpace(part) == "" {
+				continue
+			}
+			tag, err := utils.NormalizeTag(part)
+			if err != nil {
+				return nil, err
+			}
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags, nil
+}
+
+func TagMain(arguments []string) error {
+	if len(arguments) < 2 {
+		return fmt.Errorf("usage: tag <path> <tags...>")
+	}
+	tags, err := normalizeTags(arguments[1:])
+	if err != nil {
+		return err
+	}
+
+	con, err := openConnection()
+	if err != nil {
+		return err
+	}
+	defer closeConnection(con)
+
+	path, file, err := resolveEntry(con, arguments[0])
+	if err != nil {
+		return err
+	}
+	err = data.WriteTags(con, file, tags)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("tagged %s with %s\n", path, strings.Join(tags, ", "))
+
+	return nil
+}
+
+func UntagMain(arguments []string) error {
+	if len(arguments) < 1 {
+		return fmt.Errorf("usage: untag <path> [tags...]")
+	}
+	tags, err := normalizeTags(arguments[1:])
+	if err != nil {
+		return err
+	}
+
+	con, err := openConnection()
+	if err != nil {
+		return err
+	}
+	defer closeConnection(con)
+
+	path, file, err := resolveEntry(con, arguments[0])
+	if err != nil {
+		return err
+	}
+	err = data.DeleteTags(con, file, tags)
+	if err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		fmt.Printf("removed all tags from %s\n", path)
+	} else {
+		fmt.Printf("removed %s from %s\n", strings.Join(tags, ", "), path)
+	}
+
+	return nil
+}
+
+func TagsMain(arguments []string) error {
+	if len(arguments) != 1 {
+		return fmt.Errorf("usage: tags <path>")
+	}
+
+	con, err := openConnection()
+	if err != nil {
+		return err
+	}
+	defer closeConnection(con)
+
+	path, file, err := resolveEntry(con, arguments[0])
+	if err != nil {
+		return err
+	}
+	tags, err := data.GetEntryTags(con, file, path)
+	if err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		fmt.Println("no tags")
+	}
+	for _, tag := range tags {
+		if tag.InheritedFrom != "" {
+			fmt.Printf("%s\t(inherited from %s)\n", tag.Name, tag.InheritedFrom)
+			continue
+		}
+		fmt.Println(tag.Name)
+	}
+
+	return nil
+}
+
+func InheritMain(arguments []string) error {
+	if len(arguments) != 2 || (arguments[1] != "on" && arguments[1] != "off") {
+		return fmt.Errorf("usage: taginherit <tag> on|off")
+	}
+	tag, err := utils.NormalizeTag(arguments[0])
+	if err != nil {
+		return err
+	}
+
+	con, err := openConnection()
+	if err != nil {
+		return err
+	}
+	defer closeConnection(con)
+
+	err = data.UpdateTagInheritance(con, tag, arguments[1] == "on")
+	if err != nil {
+		return err
+	}
+	fmt.Printf("inheritance of %s turned %s\n", tag, arguments[1])
+
+	return nil
+}
+
+func TaggedMain(arguments []string) error {
+	if len(arguments) != 1 {
+		return fmt.Errorf("usage: tagged <tag>")
+	}
+	tag, err := utils.NormalizeTag(arguments[0])
+	if err != nil {
+		return err
+	}
+
+	loadedConfig, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	con, err := openConnection()
+	if err != nil {
+		return err
+	}
+	defer closeConnection(con)
+
+	options := search.Options{Sort: search.SortPath, Limit: taggedLimit, Ranking: loadedConfig.Ranking}
+	results, err := search.Find(con, "tag:"+tag, options)
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		fmt.Println("no entries tagged", tag)
+	}
+	for _, result := range results {
+		fmt.Println(result.Path)
+	}
+
+	return nil
+}
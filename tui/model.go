@@ -0,0 +1,296 @@
+package tui
+
+import (
+	"database/sql"
+	"icu/config"
+	"icu/data"
+	"icu/fuzzy"
+	"icu/search"
+	"icu/symbols"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	resultLimit  = 200
+	headerHeight = 3
+	footerHeight = 2
+)
+
+type searchMode int
+
+const (
+	modeQuery searchMode = iota
+	modeFuzzy
+	modeSymbol
+)
+
+type resultsMsg struct {
+	mode    searchMode
+	query   string
+	sort    string
+	results []data.SearchResult
+	symbols []data.Symbol
+	err     error
+}
+
+type savedSearchesMsg struct {
+	savedSearches []data.SavedSearch
+	err           error
+}
+
+type candidateCache struct {
+	once       sync.Once
+	candidates []data.SearchResult
+	err        error
+}
+
+func (c *candidateCache) load(con *sql.DB) ([]data.SearchResult, error) {
+	c.once.Do(func() {
+		c.candidates, c.err = data.GetSearchCandidates(con)
+	})
+
+	return c.candidates, c.err
+}
+
+type model struct {
+	con        *sql.DB
+	input      textinput.Model
+	mode       searchMode
+	options    search.Options
+	candidates *candidateCache
+	query      string
+	results    []data.SearchResult
+	symbols    []data.Symbol
+	pinned     []data.SavedSearch
+	err        error
+	cursor     int
+	offset     int
+	width      int
+	height     int
+	chosen     bool
+}
+
+func newModel(con *sql.DB, ranking config.Ranking) model {
+	input := textinput.New()
+	input.Focus()
+
+	options := search.Options{Sort: search.SortRelevance, Limit: resultLimit, Ranking: ranking}
+	m := model{con: con, input: input, options: options, candidates: &candidateCache{}}
+	m.applyMode()
+
+	return m
+}
+
+func (m *model) applyMode() {
+	switch m.mode {
+	case modeFuzzy:
+		m.input.Prompt = "~ "
+		m.input.Placeholder = "fuzzy match paths, e.g. mntorch"
+	case modeSymbol:
+		m.input.Prompt = "ƒ "
+		m.input.Placeholder = "find Go declarations, e.g. Server.Start or type Config"
+	default:
+		m.input.Prompt = "> "
+		m.input.Placeholder = "search names, paths and content"
+	}
+}
+
+func runQuery(con *sql.DB, mode searchMode, options search.Options, candidates *candidateCache, query string) tea.Cmd {
+	return func() tea.Msg {
+		if mode == modeSymbol {
+			kind, pattern := parseSymbolQuery(query)
+			found, err := symbols.Find(con, pattern, kind, resultLimit)
+			return resultsMsg{mode: mode, query: query, symbols: found, err: err}
+		}
+		if mode == modeFuzzy {
+			loaded, err := candidates.load(con)
+			if err != nil {
+				return resultsMsg{mode: mode, query: query, err: err}
+			}
+			return resultsMsg{mode: mode, query: query, results: fuzzy.Rank(query, loaded, resultLimit)}
+		}
+
+		results, err := search.Find(con, query, options)
+		return resultsMsg{mode: mode, query: query, sort: options.Sort, results: results, err: err}
+	}
+}
+
+// parseSymbolQuery splits off a leading kind, so "type Config" only lists types.
+func parseSymbolQuery(query string) (string, string) {
+	first, rest, found := strings.Cut(strings.TrimSpace(query), " ")
+	if found && slices.Contains(symbols.Kinds, first) && strings.TrimSpace(rest) != "" {
+		return first, strings.TrimSpace(rest)
+	}
+
+	return "", strings.TrimSpace(query)
+}
+
+func (m model) refresh() (model, tea.Cmd) {
+	m.query = m.input.Value()
+	if strings.TrimSpace(m.query) == "" {
+		m.results = nil
+		m.symbols = nil
+		m.err = nil
+		m.cursor = 0
+		m.offset = 0
+		return m, nil
+	}
+
+	return m, runQuery(m.con, m.mode, m.options, m.candidates, m.query)
+}
+
+func loadSavedSearches(con *sql.DB) tea.Cmd {
+	return func() tea.Msg {
+		savedSearches, err := data.GetSavedSearches(con)
+		return savedSearchesMsg{savedSearches: savedSearches, err: err}
+	}
+}
+
+func (m model) Init() tea.Cmd {
+	return tea.Batch(textinput.Blink, loadSavedSearches(m.con))
+}
+
+func (m model) showingPinned() bool {
+	return m.query == "" && m.mode == modeQuery
+}
+
+func (m model) rowCount() int {
+	if m.showingPinned() {
+		return len(m.pinned)
+	}
+	if m.mode == modeSymbol {
+		return len(m.symbols)
+	}
+
+	return len(m.results)
+}
+
+func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		m.input.Width = msg.Width - len(m.input.Prompt) - 1
+		m.clampCursor()
+		return m, nil
+
+	case savedSearchesMsg:
+		m.pinned = msg.savedSearches
+		if msg.err != nil {
+			m.err = msg.err
+		}
+		return m, nil
+
+	case resultsMsg:
+		if msg.query != m.input.Value() || msg.mode != m.mode || (msg.mode == modeQuery && msg.sort != m.options.Sort) {
+			return m, nil
+		}
+		m.results = msg.results
+		m.symbols = msg.symbols
+		m.err = msg.err
+		m.cursor = 0
+		m.offset = 0
+		return m, nil
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "esc":
+			return m, tea.Quit
+		case "tab":
+			switch m.mode {
+			case modeQuery:
+				m.mode = modeFuzzy
+			case modeFuzzy:
+				m.mode = modeSymbol
+			default:
+				m.mode = modeQuery
+			}
+			m.applyMode()
+			return m.refresh()
+		case "ctrl+s":
+			m.options.Sort = nextSortOrder(m.options.Sort)
+			return m.refresh()
+		case "enter":
+			if m.showingPinned() {
+				if len(m.pinned) == 0 {
+					return m, nil
+				}
+				m.input.SetValue("@" + m.pinned[m.cursor].Name)
+				m.input.CursorEnd()
+				return m.refresh()
+			}
+			if m.rowCount() > 0 {
+				m.chosen = true
+				return m, tea.Quit
+			}
+			return m, nil
+		case "up", "ctrl+p", "ctrl+k":
+			m.cursor--
+			m.clampCursor()
+			return m, nil
+		case "down", "ctrl+n", "ctrl+j":
+			m.cursor++
+			m.clampCursor()
+			return m, nil
+		case "pgup":
+			m.cursor -= m.visibleRows()
+			m.clampCursor()
+			return m, nil
+		case "pgdown":
+			m.cursor += m.visibleRows()
+			m.clampCursor()
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	if m.input.Value() == m.query {
+		return m, cmd
+	}
+
+	m, queryCmd := m.refresh()
+
+	return m, tea.Batch(cmd, queryCmd)
+}
+
+func nextSortOrder(current string) string {
+	for i, sortOrder := range search.SortOrders {
+		if sortOrder == current {
+			return search.SortOrders[(i+1)%len(search.SortOrders)]
+		}
+	}
+
+	return search.SortRelevance
+}
+
+func (m model) visibleRows() int {
+	rows := m.height - headerHeight - footerHeight
+	if rows < 1 {
+		return 1
+	}
+
+	return rows
+}
+
+func (m *model) clampCursor() {
+	if m.cursor >= m.rowCount() {
+		m.cursor = m.rowCount() - 1
+	}
+	if m.cursor < 0 {
+		m.cursor = 0
+	}
+
+	rows := m.visibleRows()
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	}
+	if m.cursor >= m.offset+rows {
+		m.offset = m.cursor - rows + 1
+	}
+}
@@ -0,0 +1,75 @@
+package tui
+
+import (
+	"database/sql"
+	"fmt"
+	"icu/config"
+	"icu/data"
+	"icu/db"
+	"icu/search"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func Main() error {
+	dbPath, err := db.GetDBPath()
+	if err != nil {
+		return err
+	}
+	con, err := db.CreateConnection(dbPath)
+	if err != nil {
+		return err
+	}
+	defer func(con *sql.DB) {
+		err = db.CloseConnection(con)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}(con)
+
+	loadedConfig, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	program := tea.NewProgram(newModel(con, loadedConfig.Ranking), tea.WithAltScreen())
+	finalModel, err := program.Run()
+	if err != nil {
+		return fmt.Errorf("search view exited with an error: %w", err)
+	}
+
+	final := finalModel.(model)
+	if final.mode == modeSymbol {
+		if final.chosen && final.cursor < len(final.symbols) {
+			symbol := final.symbols[final.cursor]
+			fmt.Printf("%s:%d\n", symbol.Path, symbol.Line)
+		}
+		return nil
+	}
+
+	selected, ok := final.selectedResult()
+	if !ok {
+		return nil
+	}
+
+	if final.mode == modeQuery {
+		withMatches := []data.SearchResult{selected}
+		err = search.AttachMatches(con, final.query, withMatches)
+		if err == nil && len(withMatches[0].Matches) > 0 {
+			match := withMatches[0].Matches[0]
+			fmt.Printf("%s:%d:%d\n", selected.Path, match.Line, match.Column)
+			return nil
+		}
+	}
+	fmt.Println(selected.Path)
+
+	return nil
+}
+
+func (m model) selectedResult() (data.SearchResult, bool) {
+	if !m.chosen || m.cursor >= len(m.results) {
+		return data.SearchResult{}, false
+	}
+
+	return m.results[m.cursor], true
+}
@@ -0,0 +1,181 @@
+package tui
+
+import (
+	"fmt"
+	"icu/data"
+	"icu/symbols"
+	"icu/utils"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	titleStyle    = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("12"))
+	nameStyle     = lipgloss.NewStyle().Bold(true)
+	dirStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+	metaStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("6"))
+	selectedStyle = lipgloss.NewStyle().Background(lipgloss.Color("237"))
+	errorStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+	helpStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+)
+
+const (
+	sizeColumnWidth = 10
+	timeColumnWidth = 16
+)
+
+func (m model) View() string {
+	var view strings.Builder
+
+	title := "icu search · sorted by " + m.options.Sort
+	switch m.mode {
+	case modeFuzzy:
+		title = "icu fuzzy"
+	case modeSymbol:
+		title = "icu symbols"
+	}
+	view.WriteString(titleStyle.Render(title))
+	view.WriteString("\n")
+	view.WriteString(m.input.View())
+	view.WriteString("\n\n")
+
+	switch {
+	case m.err != nil:
+		view.WriteString(errorStyle.Render(m.err.Error()))
+		view.WriteString("\n")
+	case m.showingPinned() && len(m.pinned) > 0:
+		end := m.offset + m.visibleRows()
+		if end > len(m.pinned) {
+			end = len(m.pinned)
+		}
+		for i := m.offset; i < end; i++ {
+			view.WriteString(m.renderPinned(m.pinned[i], i == m.cursor))
+			view.WriteString("\n")
+		}
+	case m.query == "":
+		view.WriteString(helpStyle.Render("start typing to search the index"))
+		view.WriteString("\n")
+	case m.rowCount() == 0:
+		view.WriteString(helpStyle.Render("no matches"))
+		view.WriteString("\n")
+	case m.mode == modeSymbol:
+		end := m.offset + m.visibleRows()
+		if end > len(m.symbols) {
+			end = len(m.symbols)
+		}
+		for i := m.offset; i < end; i++ {
+			view.WriteString(m.renderSymbol(m.symbols[i], i == m.cursor))
+			view.WriteString("\n")
+		}
+	default:
+		end := m.offset + m.visibleRows()
+		if end > len(m.results) {
+			end = len(m.results)
+		}
+		for i := m.offset; i < end; i++ {
+			view.WriteString(m.renderRow(m.results[i], i == m.cursor))
+			view.WriteString("\n")
+		}
+	}
+
+	status := fmt.Sprintf("%d results", len(m.results))
+	if m.showingPinned() {
+		status = fmt.Sprintf("%d saved searches", len(m.pinned))
+	} else if m.mode == modeSymbol {
+		status = fmt.Sprintf("%d symbols", len(m.symbols))
+	}
+	view.WriteString(helpStyle.Render(status + " · ↑/↓ move · pgup/pgdown page · tab switch mode · ctrl+s sort · enter select · esc quit"))
+
+	return view.String()
+}
+
+func (m model) renderRow(result data.SearchResult, selected bool) string {
+	width := m.width
+	if width <= 0 {
+		width = 80
+	}
+
+	size := fmt.Sprintf("%*s", sizeColumnWidth, utils.FormatSize(result.Size))
+	modified := result.ModificationTime.Format("2006-01-02 15:04")
+	pathWidth := width - sizeColumnWidth - timeColumnWidth - 4
+	if pathWidth < 10 {
+		pathWidth = 10
+	}
+
+	name := truncate(result.Name, pathWidth)
+	directory := truncate(result.ParentDirectory, pathWidth-len([]rune(name))-2)
+	padding := pathWidth - len([]rune(name)) - len([]rune(directory)) - 2
+	if padding < 0 {
+		padding = 0
+	}
+
+	row := nameStyle.Render(name) + "  " + dirStyle.Render(directory) + strings.Repeat(" ", padding) +
+		"  " + metaStyle.Render(size) + "  " + metaStyle.Render(modified)
+	if selected {
+		return selectedStyle.Width(width).Render(row)
+	}
+
+	return row
+}
+
+func (m model) renderSymbol(symbol data.Symbol, selected bool) string {
+	width := m.width
+	if width <= 0 {
+		width = 80
+	}
+
+	declaration := symbols.Describe(symbol)
+	location := fmt.Sprintf("%s:%d", symbol.Path, symbol.Line)
+	row := nameStyle.Render(declaration) + "  " + dirStyle.Render(truncate(location, width-len([]rune(declaration))-2))
+	if doc := symbols.FirstLine(symbol.Doc); doc != "" {
+		remaining := width - len([]rune(declaration)) - len([]rune(location)) - 4
+		if remaining > 10 {
+			row += "  " + metaStyle.Render(truncateEnd(doc, remaining))
+		}
+	}
+	if selected {
+		return selectedStyle.Width(width).Render(row)
+	}
+
+	return row
+}
+
+func (m model) renderPinned(savedSearch data.SavedSearch, selected bool) string {
+	width := m.width
+	if width <= 0 {
+		width = 80
+	}
+
+	name := "★ @" + savedSearch.Name
+	row := nameStyle.Render(name) + "  " + dirStyle.Render(truncate(savedSearch.Query, width-len([]rune(name))-2))
+	if selected {
+		return selectedStyle.Width(width).Render(row)
+	}
+
+	return row
+}
+
+func truncate(value string, width int) string {
+	runes := []rune(value)
+	if width <= 0 {
+		return ""
+	}
+	if len(runes) <= width {
+		return value
+	}
+	if width == 1 {
+		return "…"
+	}
+
+	return "…" + string(runes[len(runes)-width+1:])
+}
+
+func truncateEnd(value string, width int) string {
+	runes := []rune(value)
+	if len(runes) <= width {
+		return value
+	}
+
+	return string(runes[:width-1]) + "…"
+}
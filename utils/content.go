@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"bytes"
+	"unicode"
+)
+
+// NormalizeContent keeps the line structure of a document so that matches can be traced back to
+// line and column. Line endings become "\n" and every other control or separator character is
+// replaced by a single space, which keeps character columns stable.
+func NormalizeContent(contents []byte) []byte {
+	contents = bytes.ReplaceAll(contents, []byte("\r\n"), []byte("\n"))
+	contents = bytes.ReplaceAll(contents, []byte("\r"), []byte("\n"))
+
+	return bytes.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return r
+		}
+		if unicode.IsControl(r) || unicode.In(r, unicode.Cf, unicode.Co, unicode.Cs, unicode.Zl, unicode.Zp) {
+			return ' '
+		}
+		return r
+	}, contents)
+}
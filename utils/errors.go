@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"icu/data"
+	"io/fs"
+	"strings"
+	"time"
+)
+
+// Error classes stored with every ignored entry.
+const (
+	ErrorPermission = "permission"
+	ErrorVanished   = "vanished"
+	ErrorIO         = "io"
+	ErrorTooLarge   = "too_large"
+	ErrorDecode     = "decode"
+	ErrorBinary     = "binary"
+	ErrorRejected   = "rejected"
+)
+
+var ErrorClasses = []string{ErrorPermission, ErrorVanished, ErrorIO, ErrorTooLarge, ErrorDecode, ErrorBinary, ErrorRejected}
+
+var (
+	ErrTooLarge = errors.New("too large to read")
+	ErrDecode   = errors.New("could not decode")
+	ErrBinary   = errors.New("binary content")
+)
+
+// ErrorClass sorts a failed read into one of the error classes. Errors from the file system win
+// over the decode and size markers, so an unreadable archive counts as a permission problem.
+func ErrorClass(err error) string {
+	switch {
+	case errors.Is(err, fs.ErrPermission):
+		return ErrorPermission
+	case errors.Is(err, fs.ErrNotExist):
+		return ErrorVanished
+	case errors.Is(err, ErrTooLarge):
+		return ErrorTooLarge
+	case errors.Is(err, ErrDecode):
+		return ErrorDecode
+	case errors.Is(err, ErrBinary):
+		return ErrorBinary
+	case errors.Is(err, data.ErrEntryRejected):
+		return ErrorRejected
+	default:
+		return ErrorIO
+	}
+}
+
+func NewFailure(path string, err error) *data.NotAccessedPaths {
+	return &data.NotAccessedPaths{
+		Path:       path,
+		Err:        err.Error(),
+		Class:      ErrorClass(err),
+		RecordedAt: time.Now(),
+	}
+}
+
+// FailureSummary renders failure counts as e.g. "3 failures: 2 permission, 1 vanished".
+func FailureSummary(byClass map[string]int) string {
+	total := 0
+	var parts []string
+	for _, class := range ErrorClasses {
+		if byClass[class] == 0 {
+			continue
+		}
+		total += byClass[class]
+		parts = append(parts, fmt.Sprintf("%d %s", byClass[class], class))
+	}
+	if total == 0 {
+		return "no failures"
+	}
+	noun := "failures"
+	if total == 1 {
+		noun = "failure"
+	}
+
+	return fmt.Sprintf("%d %s: %s", total, noun, strings.Join(parts, ", "))
+}
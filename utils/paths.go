@@ -0,0 +1,20 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func ExpandPath(value string) (string, error) {
+	if value == "~" || strings.HasPrefix(value, "~/") {
+		homePath, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("could not expand ~: %v", err)
+		}
+		value = filepath.Join(homePath, value[1:])
+	}
+
+	return filepath.Clean(value), nil
+}
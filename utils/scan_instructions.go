@@ -23,10 +23,3 @@ var ExcludedEntries = []string{
 	".XCompose",
 	".zshrc",
 }
-
-var ContentFiles = []string{
-	".txt",
-	".md",
-	".go",
-	".py",
-}
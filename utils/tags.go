@@ -0,0 +1,21 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var tagPattern = regexp.MustCompile(`^[\p{L}\p{N}_.-]+(/[\p{L}\p{N}_.-]+)*$`)
+
+// NormalizeTag lowercases a tag and checks its syntax. Tags form a hierarchy through "/", so
+// client/acme/invoices is a child of client/acme.
+func NormalizeTag(raw string) (string, error) {
+	tag := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(raw), "#"))
+	tag = strings.Trim(tag, "/")
+	if !tagPattern.MatchString(tag) {
+		return "", fmt.Errorf("invalid tag %q, use letters, digits, '.', '-' and '_', with '/' between levels", raw)
+	}
+
+	return tag, nil
+}
@@ -0,0 +1,203 @@
+package utils
+
+import (
+	"icu/data"
+	"icu/filetype"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+)
+
+// LinkPolicy decides which symlinks and mount points the scans descend into. Links are only
+// followed to directories outside Root, since everything inside it is indexed under its own path,
+// and a link to one of Root's ancestors would walk Root again. Sync passes the indexed directories,
+// so a directory that is already indexed through one link is not followed through another. With
+// OneFilesystem set, nothing on another device than Root is descended into, like find -xdev.
+type LinkPolicy struct {
+	Root               string
+	Follow             bool
+	OneFilesystem      bool
+	IndexedDirectories map[data.FileID]string
+}
+
+// Follows reports whether the symlink at path leads to a directory the policy walks into.
+func (p LinkPolicy) Follows(path string) bool {
+	if !p.Follow {
+		return false
+	}
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return false
+	}
+	info, err := os.Stat(target)
+	if err != nil || !info.IsDir() {
+		return false
+	}
+	if indexed, ok := p.IndexedDirectories[fileIDOf(info)]; ok && indexed != path {
+		return false
+	}
+	if p.OneFilesystem && !p.onRootDevice(info) {
+		return false
+	}
+	root, err := filepath.EvalSymlinks(p.Root)
+	if err != nil {
+		root = p.Root
+	}
+
+	return !isWithin(target, root) && !isWithin(root, target)
+}
+
+// StatEntry lstats path and records its entry type and link target on entry. The returned info
+// describes the path itself, or the target directory of a symlink the policy follows.
+func (p LinkPolicy) StatEntry(path string, entry *data.EntryCollection) (fs.FileInfo, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+	entry.EntryType = filetype.Kind(info.Mode())
+	if entry.EntryType != filetype.EntrySymlink {
+		return info, nil
+	}
+
+	entry.LinkTarget, err = os.Readlink(path)
+	if err != nil {
+		return nil, err
+	}
+	if p.Follows(path) {
+		return os.Stat(path)
+	}
+
+	return info, nil
+}
+
+// StatWalked stats a path as Walk reported it: a followed symlink as the directory it leads to,
+// everything else as itself.
+func StatWalked(path string, d fs.DirEntry) (fs.FileInfo, error) {
+	if d.IsDir() {
+		return os.Stat(path)
+	}
+
+	return os.Lstat(path)
+}
+
+// onRootDevice reports whether info lives on the same file system as Root. An unreadable Root
+// does not hold anything back, the walk reports it on its own.
+func (p LinkPolicy) onRootDevice(info fs.FileInfo) bool {
+	rootInfo, err := os.Stat(p.Root)
+	if err != nil {
+		return true
+	}
+
+	return fileIDOf(info).Device == fileIDOf(rootInfo).Device
+}
+
+func fileIDOf(info fs.FileInfo) data.FileID {
+	statT := info.Sys().(*syscall.Stat_t)
+
+	return data.FileID{Device: uint64(statT.Dev), Inode: statT.Ino}
+}
+
+type walker struct {
+	policy  LinkPolicy
+	visited map[data.FileID]bool
+	fn      fs.WalkDirFunc
+}
+
+// Walk is filepath.WalkDir with the link policy applied. A followed symlink is reported as a
+// directory and walked under its own path. Every directory is entered once, however many paths
+// lead to it, which also breaks symlink loops. With OneFilesystem, a mount point is reported but
+// not entered.
+func Walk(root string, policy LinkPolicy, fn fs.WalkDirFunc) error {
+	w := walker{policy: policy, visited: make(map[data.FileID]bool), fn: fn}
+
+	return w.walk(root, "")
+}
+
+// walk walks start, or the directory behind the followed symlink link when it is set.
+func (w *walker) walk(start string, link string) error {
+	if link != "" {
+		// a trailing separator makes WalkDir resolve the link instead of reporting it
+		start = link + string(filepath.Separator)
+	}
+
+	return filepath.WalkDir(start, func(path string, d fs.DirEntry, err error) error {
+		if link != "" && path == start {
+			if err != nil {
+				return w.fn(link, d, err)
+			}
+			// the link was reported as the directory already
+			return nil
+		}
+		if err != nil {
+			return w.fn(path, d, err)
+		}
+		if d.IsDir() && !w.enter(path) {
+			return filepath.SkipDir
+		}
+		if d.IsDir() && !w.onFilesystem(path) {
+			err = w.fn(path, d, nil)
+			if err != nil {
+				return err
+			}
+			return filepath.SkipDir
+		}
+		if d.Type()&fs.ModeSymlink == 0 || slices.Contains(ExcludedEntries, d.Name()) || !w.policy.Follows(path) {
+			return w.fn(path, d, nil)
+		}
+		// a directory reached before keeps its first path, the link is reported as a link
+		if !w.enter(path) {
+			return w.fn(path, d, nil)
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return w.fn(path, d, err)
+		}
+		err = w.fn(path, fs.FileInfoToDirEntry(info), nil)
+		if err == filepath.SkipDir {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		return w.walk("", path)
+	})
+}
+
+// enter marks the directory at path as visited and reports whether it was new.
+func (w *walker) enter(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		// let the walk itself report the failure
+		return true
+	}
+	id := fileIDOf(info)
+	if w.visited[id] {
+		return false
+	}
+	w.visited[id] = true
+
+	return true
+}
+
+// onFilesystem reports whether the directory at path may be entered under the policy's
+// OneFilesystem setting.
+func (w *walker) onFilesystem(path string) bool {
+	if !w.policy.OneFilesystem {
+		return true
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return true
+	}
+
+	return w.policy.onRootDevice(info)
+}
+
+func isWithin(path string, directory string) bool {
+	return path == directory || strings.HasPrefix(path, strings.TrimSuffix(directory, string(filepath.Separator))+string(filepath.Separator))
+}